@@ -3,11 +3,15 @@ package main
 var wasmIgnoreAttributes = map[AttributeType]bool {
 	groundedAttribute: true,
 	deadAttribute: true,
+	hiddenAttribute: true,
 }
 
 var wasmIgnoreByteAttributes = map[ByteAttributeType]bool {
 	typeByteAttribute: true,
 	healthByteAttribute: true,
+	armorByteAttribute: true,
+	shieldByteAttribute: true,
+	timerByteAttribute: true,
 }
 
 // TODO: use flag
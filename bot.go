@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultBotReactionTime time.Duration = 400 * time.Millisecond
+	defaultBotAimError float64 = 0.15
+	botSightRange float64 = 20
+	botPreferredRange float64 = 6
+	botStuckVel float64 = 0.5
+)
+
+// AI-controlled player that plays by synthesizing key messages
+type Bot struct {
+	id IdType
+	number int
+	name string
+	seqNum SeqNumType
+
+	reactionTime time.Duration
+	aimError float64
+
+	target SpacedId
+	targetTimer Timer
+	aimOffset float64
+}
+
+func NewBot(id IdType, number int, reactionTime time.Duration, aimError float64) *Bot {
+	return &Bot {
+		id: id,
+		number: number,
+		name: fmt.Sprintf("Bot %d", number),
+		seqNum: 0,
+
+		reactionTime: reactionTime,
+		aimError: aimError,
+
+		target: InvalidId(),
+		targetTimer: NewTimer(0),
+		aimOffset: 0,
+	}
+}
+
+func (b Bot) GetSpacedId() SpacedId {
+	return Id(playerSpace, b.id)
+}
+
+func (b Bot) GetClientData() ClientData {
+	return ClientData {
+		Id: b.id,
+		Name: b.name,
+	}
+}
+
+func (b *Bot) CreateKeyMsg(game *Game) (KeyMsg, bool) {
+	grid := game.grid
+	object := grid.Get(b.GetSpacedId())
+	if object == nil {
+		return KeyMsg{}, false
+	}
+	player := object.(*Player)
+	if player.Dead() {
+		return KeyMsg{}, false
+	}
+
+	b.seqNum++
+	keys := make([]KeyType, 0)
+	pos := player.Pos()
+	mouse := pos
+	mouse.Add(player.Dir(), 1.0)
+
+	var dest Vec2
+	hasDest := false
+
+	if player.weapon == nil {
+		if pickup := b.findWeaponPickup(grid, pos); pickup != nil {
+			dest = pickup.Pos()
+			hasDest = true
+			if player.OverlapProfile(pickup.GetProfile()).hit {
+				keys = append(keys, interactKey)
+			}
+		}
+	}
+
+	if enemy := b.findEnemy(grid, player); enemy != nil {
+		if enemy.GetSpacedId() != b.target {
+			b.target = enemy.GetSpacedId()
+			b.targetTimer.Start(game.grid.Now())
+			b.aimOffset = (2 * rand.Float64() - 1) * b.aimError
+		}
+
+		aim := enemy.Pos()
+		aim.Sub(pos, 1.0)
+		aim.Rotate(b.aimOffset)
+		mouse = pos
+		mouse.Add(aim, 1.0)
+
+		if player.weapon != nil && b.targetTimer.Elapsed(game.grid.Now()) >= b.reactionTime {
+			keys = append(keys, mouseClick)
+		}
+
+		if !hasDest {
+			dest = enemy.Pos()
+			hasDest = Abs(dest.X - pos.X) > botPreferredRange || Abs(dest.Y - pos.Y) > 1
+		}
+	} else {
+		b.target = InvalidId()
+	}
+
+	if hasDest {
+		jump := false
+
+		// Follow the first edge of the path, if the destination is on another surface
+		if path, ok := game.findPath(pos, dest); ok && len(path) > 0 {
+			edge := path[0]
+			dest = game.getNavGraph().GetNodes()[edge.To()].Pos()
+
+			switch edge.Type() {
+			case jumpNavEdge:
+				jump = true
+			case doubleJumpNavEdge:
+				// Release and press again after the apex to trigger the air jump
+				jump = player.Vel().Y > 0 || b.seqNum % 2 == 0
+			}
+		}
+
+		if dest.X < pos.X - 0.5 {
+			keys = append(keys, leftKey)
+		} else if dest.X > pos.X + 0.5 {
+			keys = append(keys, rightKey)
+		}
+
+		stuck := Abs(player.Vel().X) < botStuckVel && Abs(dest.X - pos.X) > 0.5
+		if jump || stuck {
+			keys = append(keys, jumpKey)
+		}
+	}
+
+	dir := mouse
+	dir.Sub(pos, 1.0)
+	dir.Normalize()
+
+	return KeyMsg {
+		T: keyType,
+		S: b.seqNum,
+		K: keys,
+		M: mouse,
+		D: dir,
+	}, true
+}
+
+func (b *Bot) findWeaponPickup(grid *Grid, pos Vec2) Object {
+	var closest Object
+	closestDist := 0.0
+	for _, object := range(grid.GetObjects(pickupSpace)) {
+		pickup := object.(*Pickup)
+		if !pickup.Available() || pickup.GetPickupType() != weaponPickup {
+			continue
+		}
+
+		offset := pickup.Pos()
+		offset.Sub(pos, 1.0)
+		if closest == nil || offset.LenSquared() < closestDist {
+			closest = pickup
+			closestDist = offset.LenSquared()
+		}
+	}
+	return closest
+}
+
+func (b *Bot) findEnemy(grid *Grid, player *Player) Object {
+	var closest Object
+	closestDist := botSightRange * botSightRange
+	for _, object := range(grid.GetObjects(playerSpace)) {
+		if object.GetSpacedId() == player.GetSpacedId() || object.(*Player).Dead() {
+			continue
+		}
+
+		offset := object.Pos()
+		offset.Sub(player.Pos(), 1.0)
+		if offset.LenSquared() >= closestDist || !b.visible(grid, player.Pos(), offset) {
+			continue
+		}
+
+		closest = object
+		closestDist = offset.LenSquared()
+	}
+	return closest
+}
+
+func (b *Bot) visible(grid *Grid, pos Vec2, offset Vec2) bool {
+	line := NewLine(pos, offset)
+	for _, wall := range(grid.GetObjects(wallSpace)) {
+		if wall.HasAttribute(platformAttribute) {
+			continue
+		}
+		if wall.Intersects(line).hit {
+			return false
+		}
+	}
+	return true
+}
\ No newline at end of file
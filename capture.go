@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Version 2 stores state as a delta from the previous frame, except on keyframes
+	captureVersion uint8 = 2
+	minCaptureVersion uint8 = 1
+	captureExt string = ".cap"
+
+	// Full snapshots for joining and seeking
+	keyframeInterval SeqNumType = 300
+)
+
+type CaptureHeader struct {
+	V uint8
+	Room string
+}
+
+// Packed messages as sent to clients after a tick. State only has the props that changed since the
+// previous frame, except on keyframes where it's a full snapshot alongside the level and init message.
+type StateFrame struct {
+	Tick SeqNumType
+	Level []byte `msgpack:",omitempty"`
+	Init []byte `msgpack:",omitempty"`
+	State []byte
+	Update []byte `msgpack:",omitempty"`
+
+	snapshot *Snapshot
+	previous *Snapshot
+}
+
+func (sf StateFrame) Keyframe() bool {
+	return len(sf.Init) > 0
+}
+
+// Packed on demand, since clients get their own state and only captures need the whole world every tick
+func (sf *StateFrame) PackState() []byte {
+	if sf.State != nil || sf.snapshot == nil {
+		return sf.State
+	}
+
+	state := sf.snapshot.Msg()
+	if !sf.Keyframe() && sf.previous != nil {
+		state = sf.snapshot.Delta(sf.previous.Tick())
+	}
+	sf.State = Pack(&state)
+	return sf.State
+}
+
+// Must be called exactly once per tick since creating the update message applies deletions
+func NewStateFrame(game *Game, previous *Snapshot, keyframe bool) StateFrame {
+	frame := StateFrame {
+		Tick: game.seqNum,
+		previous: previous,
+	}
+
+	if keyframe {
+		level := game.createLevelInitMsg()
+		frame.Level = Pack(&level)
+		init := game.createGameInitMsg()
+		frame.Init = Pack(&init)
+	}
+
+	frame.snapshot = NewSnapshot(game, previous)
+	if updates, ok := game.createGameUpdateMsg(); ok {
+		frame.Update = Pack(&updates)
+	}
+	return frame
+}
+
+// Writes the outgoing state stream of a game
+type CaptureWriter struct {
+	*StreamWriter
+	lastKeyframe SeqNumType
+	hasKeyframe bool
+}
+
+func NewCaptureWriter(path string, room string) (*CaptureWriter, error) {
+	writer, err := NewStreamWriter(path)
+	if err != nil {
+		return nil, err
+	}
+
+	header := CaptureHeader {
+		V: captureVersion,
+		Room: room,
+	}
+	if err := writer.Encode(&header); err != nil {
+		writer.Close()
+		return nil, err
+	}
+
+	return &CaptureWriter {
+		StreamWriter: writer,
+		hasKeyframe: false,
+	}, nil
+}
+
+func NewRoomCaptureWriter(dir string, room string) (*CaptureWriter, error) {
+	name := fmt.Sprintf("%s-%d%s", room, time.Now().Unix(), captureExt)
+	return NewCaptureWriter(filepath.Join(dir, name), room)
+}
+
+func (cw *CaptureWriter) NeedsKeyframe(tick SeqNumType) bool {
+	return !cw.hasKeyframe || tick - cw.lastKeyframe >= keyframeInterval
+}
+
+func (cw *CaptureWriter) Write(frame StateFrame) error {
+	if frame.Keyframe() {
+		// Playback starts decompressing at keyframes
+		if err := cw.Segment(); err != nil {
+			return err
+		}
+		cw.lastKeyframe = frame.Tick
+		cw.hasKeyframe = true
+	}
+	frame.PackState()
+	return cw.Encode(&frame)
+}
+
+type captureKeyframe struct {
+	index int
+
+	// Start of the gzip member with the keyframe, and how many frames precede it there
+	offset int64
+	skip int
+}
+
+// Index of a capture file, shared by everyone watching it. Frames are read from disk with a CaptureCursor.
+type Capture struct {
+	path string
+	size int64
+	modTime time.Time
+
+	header CaptureHeader
+	numFrames int
+	keyframes []captureKeyframe
+}
+
+var captureCache = struct {
+	sync.Mutex
+	captures map[string]*Capture
+} {
+	captures: make(map[string]*Capture),
+}
+
+// Reuses the index if the file hasn't changed, which it does while the room is still being captured
+func GetCapture(path string) (*Capture, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	captureCache.Lock()
+	c, ok := captureCache.captures[path]
+	captureCache.Unlock()
+	if ok && c.size == info.Size() && c.modTime.Equal(info.ModTime()) {
+		return c, nil
+	}
+
+	c, err = LoadCapture(path)
+	if err != nil {
+		return nil, err
+	}
+	c.size = info.Size()
+	c.modTime = info.ModTime()
+
+	captureCache.Lock()
+	captureCache.captures[path] = c
+	captureCache.Unlock()
+	return c, nil
+}
+
+// Scans the file once for frame count and keyframe offsets without keeping any frames
+func LoadCapture(path string) (*Capture, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	counter := &countingReader {
+		reader: bufio.NewReader(file),
+		count: 0,
+	}
+	gz, err := gzip.NewReader(counter)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	c := &Capture {
+		path: path,
+		numFrames: 0,
+		keyframes: make([]captureKeyframe, 0),
+	}
+
+	offset := int64(0)
+	first := c.numFrames
+	for {
+		gz.Multistream(false)
+		decoder := msgpack.NewDecoder(gz)
+
+		if offset == 0 {
+			if err := decoder.Decode(&c.header); err != nil {
+				return nil, err
+			}
+			if c.header.V < minCaptureVersion || c.header.V > captureVersion {
+				return nil, fmt.Errorf("Unsupported capture version %d", c.header.V)
+			}
+		}
+
+		for {
+			frame := StateFrame{}
+			err := decoder.Decode(&frame)
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			if frame.Keyframe() {
+				c.keyframes = append(c.keyframes, captureKeyframe {
+					index: c.numFrames,
+					offset: offset,
+					skip: c.numFrames - first,
+				})
+			}
+			c.numFrames++
+		}
+
+		// Fails at the end of the file, or on the partial member of a capture still being written
+		offset = counter.count
+		first = c.numFrames
+		if err := gz.Reset(counter); err != nil {
+			break
+		}
+	}
+
+	if len(c.keyframes) == 0 {
+		return nil, fmt.Errorf("Capture %s has no keyframes", path)
+	}
+	return c, nil
+}
+
+func (c Capture) Room() string { return c.header.Room }
+func (c Capture) NumFrames() int { return c.numFrames }
+
+// Last keyframe at or before the frame index
+func (c Capture) keyframe(index int) captureKeyframe {
+	i := sort.Search(len(c.keyframes), func(i int) bool {
+		return c.keyframes[i].index > index
+	})
+	if i == 0 {
+		return c.keyframes[0]
+	}
+	return c.keyframes[i - 1]
+}
+
+// Reads frames in order from a capture. Each spectator has their own since it holds the file open.
+type CaptureCursor struct {
+	capture *Capture
+	reader *StreamReader
+	next int
+}
+
+func NewCaptureCursor(capture *Capture) *CaptureCursor {
+	return &CaptureCursor {
+		capture: capture,
+		reader: nil,
+		next: 0,
+	}
+}
+
+// Moves to the last keyframe at or before the frame index and returns the keyframe's index
+func (cc *CaptureCursor) SeekKeyframe(index int) (int, error) {
+	keyframe := cc.capture.keyframe(index)
+	cc.Close()
+
+	reader, err := NewStreamReaderAt(cc.capture.path, keyframe.offset)
+	if err != nil {
+		return 0, err
+	}
+	cc.reader = reader
+	cc.next = keyframe.index - keyframe.skip
+
+	if keyframe.offset == 0 {
+		header := CaptureHeader{}
+		if err := cc.reader.Decode(&header); err != nil {
+			return 0, err
+		}
+	}
+	for cc.next < keyframe.index {
+		if _, err := cc.Next(); err != nil {
+			return 0, err
+		}
+	}
+	return keyframe.index, nil
+}
+
+// Index of the frame Next returns
+func (cc CaptureCursor) Index() int {
+	return cc.next
+}
+
+func (cc *CaptureCursor) Next() (StateFrame, error) {
+	frame := StateFrame{}
+	if cc.reader == nil {
+		return frame, io.EOF
+	}
+	if err := cc.reader.Decode(&frame); err != nil {
+		return frame, err
+	}
+	cc.next++
+	return frame, nil
+}
+
+func (cc *CaptureCursor) Close() {
+	if cc.reader != nil {
+		cc.reader.Close()
+		cc.reader = nil
+	}
+}
+
+// Counts bytes read so gzip member offsets can be recorded. gzip reads through ReadByte when it can,
+// so it never reads past the end of the member it's decompressing.
+type countingReader struct {
+	reader *bufio.Reader
+	count int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+func (r *countingReader) ReadByte() (byte, error) {
+	b, err := r.reader.ReadByte()
+	if err == nil {
+		r.count++
+	}
+	return b, err
+}
\ No newline at end of file
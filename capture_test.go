@@ -0,0 +1,129 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Writes a capture of a game with a few players, returning the full state after every tick
+func writeTestCapture(t *testing.T, path string, ticks int) []ObjectPropMap {
+	writer, err := NewCaptureWriter(path, "test")
+	if err != nil {
+		t.Fatalf("Failed to create capture: %v", err)
+	}
+
+	game := NewGame(NewGameConfig(), NewTickClock())
+	game.loadLevel(testLevel)
+	for id := IdType(1); id <= 3; id++ {
+		game.addPlayer(id)
+	}
+
+	states := make([]ObjectPropMap, 0, ticks)
+	var snapshot *Snapshot
+	for i := 0; i < ticks; i++ {
+		game.updateState(game.seqNum + 1, frameTime)
+		frame := NewStateFrame(game, snapshot, writer.NeedsKeyframe(game.seqNum))
+		if err := writer.Write(frame); err != nil {
+			t.Fatalf("Failed to write frame %d: %v", i, err)
+		}
+		snapshot = frame.snapshot
+
+		state := GameStateMsg{}
+		if err := unpackState(Pack(snapshot.Msg()), &state); err != nil {
+			t.Fatalf("Failed to unpack state %d: %v", i, err)
+		}
+		states = append(states, state.Os)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close capture: %v", err)
+	}
+	return states
+}
+
+func mergeTestState(t *testing.T, state ObjectPropMap, frame StateFrame) {
+	msg := GameStateMsg{}
+	if err := unpackState(frame.State, &msg); err != nil {
+		t.Fatalf("Failed to unpack frame at tick %d: %v", frame.Tick, err)
+	}
+	for space, objects := range(msg.Os) {
+		if _, ok := state[space]; !ok {
+			state[space] = make(SpacedPropMap)
+		}
+		for id, props := range(objects) {
+			if _, ok := state[space][id]; !ok {
+				state[space][id] = make(PropMap)
+			}
+			for prop, data := range(props) {
+				state[space][id][prop] = data
+			}
+		}
+	}
+}
+
+func TestCaptureIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test" + captureExt)
+	ticks := 2 * int(keyframeInterval) + 10
+	writeTestCapture(t, path, ticks)
+
+	capture, err := GetCapture(path)
+	if err != nil {
+		t.Fatalf("Failed to load capture: %v", err)
+	}
+	if capture.NumFrames() != ticks {
+		t.Errorf("Capture has %d frames, want %d", capture.NumFrames(), ticks)
+	}
+	if len(capture.keyframes) != 3 {
+		t.Errorf("Capture has %d keyframes, want 3", len(capture.keyframes))
+	}
+
+	cached, err := GetCapture(path)
+	if err != nil || cached != capture {
+		t.Errorf("Capture wasn't shared between loads")
+	}
+}
+
+func TestCaptureCursor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test" + captureExt)
+	ticks := 2 * int(keyframeInterval) + 10
+	states := writeTestCapture(t, path, ticks)
+
+	capture, err := GetCapture(path)
+	if err != nil {
+		t.Fatalf("Failed to load capture: %v", err)
+	}
+
+	cursor := NewCaptureCursor(capture)
+	defer cursor.Close()
+
+	for _, index := range([]int { 0, 10, int(keyframeInterval) - 1, int(keyframeInterval), int(keyframeInterval) + 50, ticks - 1 }) {
+		keyIndex, err := cursor.SeekKeyframe(index)
+		if err != nil {
+			t.Fatalf("Failed to seek to %d: %v", index, err)
+		}
+		if keyIndex > index || index - keyIndex >= int(keyframeInterval) {
+			t.Errorf("Seeking to %d started at keyframe %d", index, keyIndex)
+		}
+
+		// Deltas applied on top of the keyframe should rebuild the full state
+		state := make(ObjectPropMap)
+		for cursor.Index() <= index {
+			frame, err := cursor.Next()
+			if err != nil {
+				t.Fatalf("Failed to read frame %d: %v", cursor.Index(), err)
+			}
+			if cursor.Index() - 1 == keyIndex && !frame.Keyframe() {
+				t.Errorf("Frame %d should be a keyframe", keyIndex)
+			}
+			if frame.Tick != SeqNumType(cursor.Index()) {
+				t.Errorf("Frame %d has tick %d", cursor.Index() - 1, frame.Tick)
+			}
+			mergeTestState(t, state, frame)
+		}
+
+		if !reflect.DeepEqual(state, states[index]) {
+			t.Errorf("State rebuilt at frame %d doesn't match the snapshot", index)
+		}
+	}
+}
\ No newline at end of file
@@ -31,13 +31,13 @@ func NewReverseCharger(juice int, chargeTime time.Duration) Charger {
 	return c
 }
 
-func (c Charger) GetJuice() uint8 {
-	c.refill()
+func (c Charger) GetJuice(now time.Time) uint8 {
+	c.refill(now)
 	return uint8(c.juice)
 }
 
-func (c Charger) GetPercent() uint8 {
-	c.refill()
+func (c Charger) GetPercent(now time.Time) uint8 {
+	c.refill(now)
 	return uint8(c.juice / c.maxJuice)
 }
 
@@ -45,13 +45,13 @@ func (c *Charger) SetDelay(delay time.Duration) {
 	c.charger.SetDelay(delay)
 }
 
-func (c *Charger) SetJuice(juice int) {
+func (c *Charger) SetJuice(juice int, now time.Time) {
 	c.juice = juice
-	c.charger.Start()
+	c.charger.Start(now)
 }
 
-func (c *Charger) UseJuice(juice int) {
-	c.refill()
+func (c *Charger) UseJuice(juice int, now time.Time) {
+	c.refill(now)
 
 	if c.reversed {
 		juice = -juice
@@ -59,11 +59,11 @@ func (c *Charger) UseJuice(juice int) {
 
 	c.juice -= juice
 	c.cap()
-	c.charger.Start()
+	c.charger.Start(now)
 }
 
-func (c *Charger) refill() {
-	juice := int(math.Round(c.charger.Lerp(0, float64(c.maxJuice))))
+func (c *Charger) refill(now time.Time) {
+	juice := int(math.Round(c.charger.Lerp(now, 0, float64(c.maxJuice))))
 
 	if c.reversed {
 		juice = -juice
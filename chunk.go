@@ -0,0 +1,112 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+)
+
+const (
+	// Room for the SCTP, DTLS and UDP headers under a typical 1280 byte path MTU
+	defaultStateChunkSize int = 1100
+	minStateChunkSize int = 256
+	maxStateChunkSize int = 16384
+
+	// Chunks received are acked as a bitmask
+	maxStateChunks int = 32
+
+	// Message header and per space maps, which object sizes don't include
+	stateChunkOverhead int = 32
+)
+
+// Reads STATE_CHUNK_SIZE, falling back to the default if unset or invalid
+func StateChunkSizeFromEnv() int {
+	return sizeFromEnv("STATE_CHUNK_SIZE", defaultStateChunkSize, minStateChunkSize, maxStateChunkSize)
+}
+
+func sizeFromEnv(name string, defaultSize int, minSize int, maxSize int) int {
+	value := os.Getenv(name)
+	if len(value) == 0 {
+		return defaultSize
+	}
+
+	size, err := strconv.Atoi(value)
+	if err != nil || size < minSize || size > maxSize {
+		log.Printf("Invalid %s %s, should be %d-%d", name, value, minSize, maxSize)
+		return defaultSize
+	}
+	return size
+}
+
+// Bytes an object adds to a msgpack state message
+func PackedObjectSize(sid SpacedId, props PropMap) int {
+	// Plus the id key
+	return len(Pack(props)) + 3
+}
+
+// Splits the objects for a tick into state messages that each stay under the chunk size, since the
+// unreliable data channel drops anything that doesn't fit in a packet. Objects are never split, so
+// every chunk can be applied on its own when others are lost.
+type StateChunker struct {
+	tick SeqNumType
+	chunkSize int
+
+	msgs []GameStateMsg
+	ids [][]SpacedId
+	used int
+}
+
+func NewStateChunker(tick SeqNumType, chunkSize int) *StateChunker {
+	sc := &StateChunker {
+		tick: tick,
+		chunkSize: chunkSize,
+
+		msgs: make([]GameStateMsg, 0, 1),
+		ids: make([][]SpacedId, 0, 1),
+		used: 0,
+	}
+	sc.newChunk()
+	return sc
+}
+
+// Bytes is how much the object adds to a message. Returns false if every chunk is full.
+func (sc *StateChunker) Add(sid SpacedId, props PropMap, bytes int) bool {
+	last := len(sc.msgs) - 1
+	if len(sc.ids[last]) > 0 && sc.used + bytes > sc.chunkSize - stateChunkOverhead {
+		if len(sc.msgs) >= maxStateChunks {
+			return false
+		}
+		sc.newChunk()
+		last++
+	}
+	sc.used += bytes
+
+	objects := sc.msgs[last].Os
+	if _, ok := objects[sid.GetSpace()]; !ok {
+		objects[sid.GetSpace()] = make(SpacedPropMap)
+	}
+	objects[sid.GetSpace()][sid.GetId()] = props
+	sc.ids[last] = append(sc.ids[last], sid)
+	return true
+}
+
+// Always at least one message, so the client still acks the tick when nothing changed
+func (sc *StateChunker) Msgs() []GameStateMsg {
+	return sc.msgs
+}
+
+// Objects in each chunk
+func (sc *StateChunker) Ids() [][]SpacedId {
+	return sc.ids
+}
+
+func (sc *StateChunker) newChunk() {
+	sc.msgs = append(sc.msgs, GameStateMsg {
+		T: objectDataType,
+		S: sc.tick,
+		C: uint8(len(sc.msgs)),
+		Os: make(ObjectPropMap),
+	})
+	sc.ids = append(sc.ids, make([]SpacedId, 0))
+	sc.used = 0
+}
\ No newline at end of file
@@ -8,40 +8,79 @@ import (
 	"log"
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+const (
+	// Unordered and unreliable, for state that's superseded every tick
+	dataChannelLabel string = "data"
+	// Ordered and reliable, for game events that would otherwise wait behind lost packets on the websocket
+	eventChannelLabel string = "events"
+
+	// Give up on WebRTC after this long and send everything over the websocket
+	webRTCTimeout time.Duration = 10 * time.Second
+)
+
+// Owner of clients, e.g. a Room, that processes their messages on its own goroutine
+type ClientHost interface {
+	Incoming() chan<- IncomingMsg
+	Unregister() chan<- *Client
+}
+
 type Client struct {
-	room *Room
+	host ClientHost
 	ws *websocket.Conn
 	wrtc *webrtc.PeerConnection
 	dc *webrtc.DataChannel
+	edc *webrtc.DataChannel
 	mu sync.Mutex
 
+	// Set when WebRTC doesn't connect in time, e.g. behind a restrictive firewall
+	wsOnly int32
+	// Guarded by mu, since WebRTC callbacks race with the room
+	fallback *time.Timer
+
 	id IdType
 	name string
 	voice bool
+	latency time.Duration
+	pinger *Pinger
+
+	// Whether state is sent with PackCompact instead of Pack
+	compact bool
+
+	// Which objects the client gets state for, and which of those fit in each message
+	interest *Interest
+	priority *Priority
 }
 
-func NewClient(room* Room, ws *websocket.Conn, name string) *Client {
+func NewClient(host ClientHost, id IdType, ws *websocket.Conn, name string, compact bool) *Client {
 	client := &Client {
-		room: room,
+		host: host,
 		ws: ws,
 		wrtc: nil,
 		dc: nil,
+		edc: nil,
+		wsOnly: 0,
+		fallback: nil,
 
-		id: room.nextClientId,
+		id: id,
 		name: name,
 		voice: false,
+		latency: 0,
+		pinger: NewPinger(),
+		compact: compact,
+		interest: NewInterest(id),
+		priority: NewPriority(),
 	}
 	go client.run()
-
-	room.nextClientId += 1
 	return client
 }
 
 func (c *Client) run() {
 	defer func() {
-		c.room.unregister <- c
+		c.host.Unregister() <- c
 	}()
 
 	for {
@@ -58,7 +97,22 @@ func (c *Client) run() {
 			b: b,
 			client: c,
 		}
-		c.room.incoming <- imsg
+		c.host.Incoming() <- imsg
+	}
+}
+
+func (c *Client) Close() {
+	c.mu.Lock()
+	if c.fallback != nil {
+		c.fallback.Stop()
+	}
+	c.mu.Unlock()
+
+	c.ws.Close()
+	for _, dc := range([]*webrtc.DataChannel{c.dc, c.edc}) {
+		if dc != nil {
+			dc.Close()
+		}
 	}
 }
 
@@ -78,7 +132,26 @@ func (c *Client) Send(msg interface{}) error {
 	return c.SendBytes(b)
 }
 
+func (c *Client) WebSocketOnly() bool {
+	return atomic.LoadInt32(&c.wsOnly) != 0
+}
+
+// Sends over the event channel once it's open, otherwise over the websocket
 func (c *Client) SendBytes(b []byte) error {
+	if !c.WebSocketOnly() && c.edc != nil && c.edc.ReadyState() == webrtc.DataChannelStateOpen {
+		return c.edc.Send(b)
+	}
+	return c.SendBytesWS(b)
+}
+
+// WebRTC signaling has to use the websocket, since it's what sets up the data channels. Also the
+// fallback for everything else.
+func (c *Client) SendWS(msg interface{}) error {
+	b := Pack(msg)
+	return c.SendBytesWS(b)
+}
+
+func (c *Client) SendBytesWS(b []byte) error {
 	// Lock required to synchronize writes from room and WebRTC callbacks
 	c.mu.Lock()
 	err := c.ws.WriteMessage(websocket.BinaryMessage, b)
@@ -92,32 +165,38 @@ func (c *Client) SendUDP(msg interface{}) error {
 }
 
 func (c *Client) SendBytesUDP(b []byte) error {
+	if c.WebSocketOnly() {
+		return c.SendBytesWS(b)
+	}
 	if c.dc == nil {
 		return errors.New("Data channel not initialized")
 	}
 	return c.dc.Send(b)
 }
 
-func (c *Client) InitWebRTC(onSuccess func()) error {
+func (c *Client) InitWebRTC(config WebRTCConfig, onSuccess func()) error {
 	var err error
-	config := webrtc.Configuration{
-		ICEServers: []webrtc.ICEServer{
-			{
-				URLs: []string{
-					"stun:stun.l.google.com:19302",
-					"stun:stun2.l.google.com:19302",
-					"stun:openrelay.metered.ca:80",
-				},
-			},
-		},
-	}
 
 	log.Printf("Starting new WebRTC connection")
-	c.wrtc, err = webrtc.NewPeerConnection(config)
+	c.wrtc, err = config.NewPeerConnection()
 	if err != nil {
 		return err
 	}
 
+	// Start the client either way, so players who can't use WebRTC can still play
+	var started sync.Once
+	start := func() {
+		started.Do(onSuccess)
+	}
+	c.mu.Lock()
+	c.fallback = time.AfterFunc(webRTCTimeout, func() {
+		log.Printf("WebRTC didn't connect for %s within %v, falling back to websocket", c.GetDisplayName(), webRTCTimeout)
+		atomic.StoreInt32(&c.wsOnly, 1)
+		c.wrtc.Close()
+		start()
+	})
+	c.mu.Unlock()
+
 	c.wrtc.OnConnectionStateChange(func(s webrtc.PeerConnectionState) {
 		log.Printf("WebRTC connection state for %s has changed: %s", c.GetDisplayName(), s.String())
 	})
@@ -128,23 +207,45 @@ func (c *Client) InitWebRTC(onSuccess func()) error {
 		Ordered: &ordered,
 		MaxRetransmits: &maxRetransmits,
 	}
-	c.dc, err = c.wrtc.CreateDataChannel("data", dcInit)
+	c.dc, err = c.wrtc.CreateDataChannel(dataChannelLabel, dcInit)
 	if err != nil {
 		return err
 	}
 
-	c.dc.OnOpen(func() {
-		onSuccess()
-		log.Printf("Opened data channel for %s: %s-%d", c.GetDisplayName(), c.dc.Label(), c.dc.ID())
-	})
+	// Ordered and reliable by default
+	c.edc, err = c.wrtc.CreateDataChannel(eventChannelLabel, nil)
+	if err != nil {
+		return err
+	}
 
-	c.dc.OnMessage(func(msg webrtc.DataChannelMessage) {
-		imsg := IncomingMsg{
-			b: msg.Data,
-			client: c,
-		}
-		c.room.incoming <- imsg
-	})
+	// Start once both are open, so all messages after the client is initialized use the same channel
+	pending := int32(2)
+	for _, dc := range([]*webrtc.DataChannel{c.dc, c.edc}) {
+		dc := dc
+		dc.OnOpen(func() {
+			log.Printf("Opened data channel for %s: %s-%d", c.GetDisplayName(), dc.Label(), *dc.ID())
+			if atomic.AddInt32(&pending, -1) != 0 {
+				return
+			}
+
+			c.mu.Lock()
+			stopped := c.fallback.Stop()
+			c.mu.Unlock()
+
+			// Too late if the fallback already fired or the client closed, so stay on the websocket
+			if stopped {
+				start()
+			}
+		})
+
+		dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+			imsg := IncomingMsg{
+				b: msg.Data,
+				client: c,
+			}
+			c.host.Incoming() <- imsg
+		})
+	}
 
 	c.wrtc.OnICECandidate(func(ice *webrtc.ICECandidate) {
 		if ice == nil {
@@ -156,7 +257,7 @@ func (c *Client) InitWebRTC(onSuccess func()) error {
 			JSON: ice.ToJSON(),
 		}
 
-		c.Send(&candidateMsg)
+		c.SendWS(&candidateMsg)
 	})
 
 	return nil
@@ -164,6 +265,9 @@ func (c *Client) InitWebRTC(onSuccess func()) error {
 
 func (c *Client) processWebRTCOffer(json interface{}) error {
 	log.Printf("Received WebRTC offer for %s", c.GetDisplayName())
+	if c.WebSocketOnly() {
+		return nil
+	}
 
 	offer, ok := json.(map[string]interface{})
 	if !ok {
@@ -190,12 +294,15 @@ func (c *Client) processWebRTCOffer(json interface{}) error {
 		T: answerType,
 		JSON: answer,
 	}
-	c.Send(&answerMsg)
+	c.SendWS(&answerMsg)
 	return nil
 }
 
 func (c *Client) processWebRTCCandidate(json interface{}) error {
 	log.Printf("Received WebRTC ICE candidate for %s", c.GetDisplayName())
+	if c.WebSocketOnly() {
+		return nil
+	}
 
 	candidate, ok := json.(map[string]interface{})
 	if !ok {
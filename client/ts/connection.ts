@@ -6,17 +6,8 @@ import { LogUtil, Util } from './util.js'
 type MessageHandler = (msg : any) => void;
 type MessageSender = () => void;
 class Connection {
-	private readonly _iceConfig = {
-    	"iceServers": [
-	    	{
-	    		urls: [
-	                "stun:stun1.l.google.com:19302",
-	                "stun:stun2.l.google.com:19302",
-					"stun:openrelay.metered.ca:80",
-	    		]
-	    	}
-	    ]
-    };
+	// A bit longer than the server waits, so it gives up first
+	private readonly _webRTCTimeout = 12000;
 
 	private _handlers : Map<number, MessageHandler[]>;
 	private _senders : Map<number, MessageSender>;
@@ -24,20 +15,42 @@ class Connection {
 	private _id : number;
 
 	private _ws : WebSocket;
+	// From the server's init message, so both peers use the same ICE servers
+	private _iceConfig : RTCConfiguration;
+	private _dcSuccess : () => void;
 	private _wrtc : RTCPeerConnection;
 	private _dc : RTCDataChannel;
+	// Ordered and reliable, so game events don't wait behind lost packets on the websocket
+	private _edc : RTCDataChannel;
 	private _candidates : Array<RTCIceCandidate>;
+	// WebRTC never connected, so everything goes over the websocket
+	private _wsOnly : boolean;
 	private _pinger : Pinger;
 
 	constructor() {
 		this._handlers = new Map();
 		this._senders = new Map();
+		this._wsOnly = false;
 	}
 
 	setup() : void {
 		this.addHandler(initType, (msg : any) => {
 			this._id = msg.Client.Id;
 			LogUtil.d("Initialized connection with id " + this._id);
+
+			if (Util.defined(msg.WebRTC)) {
+				this._iceConfig = {
+					iceServers: msg.WebRTC.Servers.map((server : any) => {
+						return {
+							urls: server.URLs,
+							username: server.Username,
+							credential: server.Credential,
+						};
+					}),
+					iceTransportPolicy: msg.WebRTC.Relay ? "relay" : "all",
+				};
+			}
+			this.initWebRTC(this._dcSuccess);
 		});
 		this.addHandler(answerType, (msg : any) => { this.setRemoteDescription(msg); });
 		this.addHandler(candidateType, (msg : any) => { this.addIceCandidate(msg); });
@@ -48,11 +61,16 @@ class Connection {
 	wsReady() : boolean { return Util.defined(this._ws) && this._ws.readyState === 1; }
 	dcConnecting() : boolean { return Util.defined(this._dc) && (this._dc.readyState === "connecting" || this._dc.readyState === "open"); }
 	dcReady() : boolean { return Util.defined(this._dc) && this._dc.readyState === "open"; }
-	ready() : boolean { return Util.defined(this._id) && this.wsReady() && this.dcReady(); }
+	edcReady() : boolean { return Util.defined(this._edc) && this._edc.readyState === "open"; }
+	ready() : boolean { return Util.defined(this._id) && this.wsReady() && (this.dcReady() || this._wsOnly); }
 
 	connect(room : string, name : string, socketSuccess : () => void, dcSuccess : () => void) : void {
 		const prefix = Util.isDev() ? "ws://" : "wss://"
-		const endpoint = prefix + window.location.host + "/newclient/room=" + room + "&name=" + name;
+		const params = new URLSearchParams(window.location.search);
+
+		// Watch a captured match instead of joining a room
+		const path = params.has("replay") ? "/replay/file=" + params.get("replay") : "/newclient/room=" + room;
+		const endpoint = prefix + window.location.host + path + "&name=" + name + "&codec=compact";
 		this.initWebSocket(endpoint, socketSuccess, dcSuccess);
 	}
 
@@ -96,18 +114,19 @@ class Connection {
 		return this._pinger.ping();
 	}
 
+	// Uses the event channel once it's open, otherwise the websocket
 	send(msg : any) : boolean {
-		if (!this.wsReady()) {
-			LogUtil.d("Trying to send message (type " + msg.T + ") before connection is ready!");
-			return false;
+		if (this.edcReady()) {
+			this._edc.send(encode(msg));
+			return true;
 		}
-
-		const buffer = encode(msg);
-		this._ws.send(buffer);
-		return true;
+		return this.sendWebSocket(msg);
 	}
 
 	sendData(msg :any) : boolean {
+		if (this._wsOnly) {
+			return this.sendWebSocket(msg);
+		}
 		if (!this.dcReady()) {
 			LogUtil.d("Trying to send message (type " + msg.T + ") before data channel is ready!");
 			return false;
@@ -118,8 +137,22 @@ class Connection {
 		return true;
 	}
 
+	// WebRTC signaling has to use the websocket, since it's what sets up the data channels. Also the
+	// fallback for everything else.
+	private sendWebSocket(msg : any) : boolean {
+		if (!this.wsReady()) {
+			LogUtil.d("Trying to send message (type " + msg.T + ") before connection is ready!");
+			return false;
+		}
+
+		const buffer = encode(msg);
+		this._ws.send(buffer);
+		return true;
+	}
+
 	private initWebSocket(endpoint : string, socketSuccess : () => void, dcSuccess : () => void) : void {
-		if (this.wsReady() && !this.dcConnecting()) {
+		this._dcSuccess = dcSuccess;
+		if (this.wsReady() && !this.dcConnecting() && !this._wsOnly) {
 			this.initWebRTC(dcSuccess);
 			return;
 		}
@@ -138,8 +171,8 @@ class Connection {
 			if (!Util.defined(this._pinger)) {
 				this._pinger = new Pinger();
 			}
-			socketSuccess();		
-			this.initWebRTC(dcSuccess);
+			// WebRTC starts once the init message arrives with the ICE servers
+			socketSuccess();
 		};
 		this._ws.onmessage = (event) => {	
 			this.handlePayload(event.data);
@@ -155,6 +188,9 @@ class Connection {
 			if (Util.defined(this._dc)) {
 				this._dc.close();
 			}
+			if (Util.defined(this._edc)) {
+				this._edc.close();
+			}
 			ui.disconnected();
 		};
 	}
@@ -173,6 +209,10 @@ class Connection {
 		if (Util.defined(this._dc)) {
 			this._dc.close();
 		}
+		if (Util.defined(this._edc)) {
+			this._edc.close();
+			this._edc = null;
+		}
 		this._dc = this._wrtc.createDataChannel("data", dataChannelConfig);
 		this._candidates = new Array<RTCIceCandidate>();
 
@@ -182,21 +222,42 @@ class Connection {
 
 		this._wrtc.onicecandidate = (event) => {
 			if (event && event.candidate) {
-				this.send({T: candidateType, JSON: event.candidate.toJSON() });
+				this.sendWebSocket({T: candidateType, JSON: event.candidate.toJSON() });
 			}		
 		};
 
 		this._wrtc.createOffer((description) => {
 			this._wrtc.setLocalDescription(description);
-			this.send({ T: offerType, JSON: description });			
+			this.sendWebSocket({ T: offerType, JSON: description });			
 		}, () => {});
 
+		// The server opens both channels, and only starts the game once they're open
+		let channels = 0;
 		this._wrtc.ondatachannel = (event) => {
-			console.log("Successfully created data channel");
-			this._dc = event.channel;
-			this._dc.onmessage = (event) => { this.handlePayload(event.data); }	
-			dcSuccess();
+			console.log("Successfully created data channel " + event.channel.label);
+			if (event.channel.label === "events") {
+				this._edc = event.channel;
+			} else {
+				this._dc = event.channel;
+			}
+			event.channel.onmessage = (event) => { this.handlePayload(event.data); }
+
+			channels++;
+			if (channels === 2 && !this._wsOnly) {
+				dcSuccess();
+			}
 		};
+
+		setTimeout(() => {
+			if (channels === 2) {
+				return;
+			}
+
+			LogUtil.d("WebRTC failed to connect, falling back to websocket");
+			this._wsOnly = true;
+			this._wrtc.close();
+			dcSuccess();
+		}, this._webRTCTimeout);
 	}
 
 	private setRemoteDescription(msg : any) : void {
@@ -229,9 +290,11 @@ class Connection {
 	}
 
 	private handlePayload(payload : any) {
-		const msg : any = decode(new Uint8Array(payload));
+		const bytes = new Uint8Array(payload);
 
-		if (!Util.defined(msg.T)) {
+		// Compact state is decoded by the WASM module, which shares the server's schema
+		const msg : any = bytes[0] === compactMagic ? JSON.parse(wasmDecodeCompact(bytes)) : decode(bytes);
+		if (!Util.defined(msg) || !Util.defined(msg.T)) {
 			LogUtil.d("Error! Missing type for payload");
 			return;
 		}
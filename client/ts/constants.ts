@@ -1,5 +1,6 @@
 /* WASM variables */
 declare var frameMillis : number;
+declare var compactMagic : number;
 
 declare var pingType: number;
 declare var candidateType : number;
@@ -23,6 +24,7 @@ declare var objectDataType : number;
 declare var objectUpdateType : number;
 declare var playerInitType : number;
 declare var levelInitType : number;
+declare var killcamType : number;
 
 declare var playerSpace : number;
 declare var wallSpace : number;
@@ -41,9 +43,21 @@ declare var bazookaWeapon : number;
 declare var sniperWeapon : number;
 declare var starWeapon : number;
 
+declare var weaponPickup : number;
+declare var healthPickup : number;
+declare var armorPickup : number;
+declare var ammoPickup : number;
+declare var powerUpPickup : number;
+
+declare var speedPowerUp : number;
+declare var damagePowerUp : number;
+declare var jumpPowerUp : number;
+declare var gravityPowerUp : number;
+
 declare var objectStatesProp : number;
 declare var initializedProp : number;
 declare var deletedProp : number;
+declare var hiddenProp : number;
 
 declare var attributesProp : number;
 declare var byteAttributesProp : number;
@@ -63,6 +77,8 @@ declare var hitsProp : number;
 declare var scoreProp : number;
 declare var killProp : number;
 declare var deathProp : number;
+declare var powerUpsProp : number;
+declare var keySeqNumProp : number;
 
 declare var stairAttribute : number;
 declare var platformAttribute : number;
@@ -70,10 +86,16 @@ declare var groundedAttribute : number;
 declare var solidAttribute : number;
 declare var attachedAttribute : number;
 declare var deadAttribute : number;
+declare var hiddenAttribute : number;
 
 declare var typeByteAttribute : number;
 declare var healthByteAttribute : number;
 declare var juiceByteAttribute : number;
+declare var armorByteAttribute : number;
+declare var shieldByteAttribute : number;
+declare var pickupByteAttribute : number;
+declare var amountByteAttribute : number;
+declare var timerByteAttribute : number;
 
 declare var upKey : number;
 declare var downKey : number;
@@ -89,8 +111,10 @@ declare var wasmAdd : any;
 declare var wasmHas : any;
 declare var wasmDelete : any;
 declare var wasmUpdateKeys : any;
+declare var wasmStepPlayer : any;
 declare var wasmGetData : any;
 declare var wasmSetData : any;
 declare var wasmLoadLevel : any;
 declare var wasmUpdateState : any;
-declare var wasmGetStats : any;
\ No newline at end of file
+declare var wasmGetStats : any;
+declare var wasmDecodeCompact : any;
\ No newline at end of file
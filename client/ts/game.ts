@@ -2,6 +2,7 @@ import * as THREE from 'three';
 
 import { connection } from './connection.js'
 import { Keys } from './keys.js'
+import { Killcam } from './killcam.js'
 import { Model, loader } from './loader.js'
 import { options } from './options.js'
 import { RenderBolt } from './render_bolt.js'
@@ -29,6 +30,7 @@ export enum GameState {
 
 class Game {
 	private readonly _statsInterval = 500;
+	private readonly _maxPendingKeys = 120;
 	private readonly _objectMaterial = new THREE.MeshStandardMaterial( {color: 0x444444, shadowSide: THREE.FrontSide } );
 
 	private _id : number;
@@ -38,6 +40,14 @@ class Game {
 	private _keys : Keys;
 	private _keySeqNum : number;
 	private _lastSeqNum : number;
+	// Bitmask of the chunks received for _lastSeqNum
+	private _lastChunks : number;
+
+	// Inputs not yet acknowledged by the server, replayed on top of each authoritative state
+	private _pendingKeys : Array<{ [k: string]: any }>;
+	// Server tick of the state that last acknowledged inputs, which pending inputs are replayed after
+	private _ackSeqNum : number;
+	private _reconcile : boolean;
 
 	private _numObjectsAdded : number;
 	private _numObjectsUpdated : number;
@@ -54,6 +64,11 @@ class Game {
 		this._keys = new Keys();
 		this._keySeqNum = 0;
 		this._lastSeqNum = 0;
+		this._lastChunks = 0;
+
+		this._pendingKeys = new Array();
+		this._ackSeqNum = 0;
+		this._reconcile = false;
 
 		this._numObjectsAdded = 0;
 		this._numObjectsUpdated = 0;
@@ -64,6 +79,7 @@ class Game {
 		connection.addHandler(objectUpdateType, (msg : { [k: string]: any }) => { this.updateGameState(msg); });
 		connection.addHandler(playerInitType, (msg : { [k: string]: any }) => { this.initPlayer(msg); });
 		connection.addHandler(levelInitType, (msg : { [k: string]: any }) => { this.initLevel(msg); });
+		connection.addHandler(killcamType, (msg : { [k: string]: any }) => { this.killcam().play(msg); });
 	}
 
 	hasId() : boolean { return this._id >= 0; }
@@ -71,6 +87,7 @@ class Game {
 	state() : GameState { return this._state; }
 	sceneMap() : SceneMap { return this._sceneMap; }
 	sceneComponent(type : SceneComponentType) : SceneComponent { return this._sceneMap.getComponent(type); }
+	killcam() : Killcam { return <Killcam>this.sceneComponent(SceneComponentType.KILLCAM); }
 
 	startRender() : void { this.animate(); }
 	setState(state : GameState) { this._state = state; }
@@ -119,7 +136,14 @@ class Game {
 
 			this._keySeqNum++;
 			const msg = this._keys.keyMsg(this._keySeqNum);
+			// Ack the latest state so the server can send only what changed since
+			msg.Key.A = this._lastSeqNum;
+			msg.Key.AC = this._lastChunks;
 			connection.sendData(msg);
+			this._pendingKeys.push(msg.Key);
+			if (this._pendingKeys.length > this._maxPendingKeys) {
+				this._pendingKeys.shift();
+			}
 			if (this._keys.changed()) {
 				connection.send(msg);
 			}
@@ -132,11 +156,15 @@ class Game {
 	private updateGameState(msg : { [k: string]: any }) : void {
 		const seqNum = msg.S;
 		if (msg.T === objectDataType) {
-			if (seqNum <= this._lastSeqNum) {
+			// Each chunk of a tick holds different objects, so apply whichever arrive
+			if (seqNum < this._lastSeqNum) {
 				return;
-			}  else {
+			} else if (seqNum > this._lastSeqNum) {
 				this._lastSeqNum = seqNum;
+				this._lastChunks = 0;
 			}
+			const chunk = Util.defined(msg.C) ? msg.C : 0;
+			this._lastChunks = (this._lastChunks | (1 << chunk)) >>> 0;
 		}
 
 		if (Util.defined(msg.Os)) {
@@ -154,6 +182,12 @@ class Game {
 					continue;
 				}
 
+				// Out of range for now, the server sends everything again if it comes back
+				if (object.hasOwnProperty(hiddenProp) && object[hiddenProp]) {
+					this.sceneMap().hide(space, id);
+					continue;
+				}
+
 				if (!this.sceneMap().has(space, id)) {
 					let renderObj;
 					if (space === playerSpace) {
@@ -184,10 +218,32 @@ class Game {
 
 				this.sceneMap().setData(space, id, object, seqNum);
 				this._numObjectsUpdated++;
+
+				if (space === playerSpace && id === this.id() && object.hasOwnProperty(keySeqNumProp)) {
+					this.ackKeys(object[keySeqNumProp], seqNum);
+				}
 			}
 		}
 	}
 
+	private ackKeys(keySeqNum : number, seqNum : number) : void {
+		while (this._pendingKeys.length > 0 && this._pendingKeys[0].S <= keySeqNum) {
+			this._pendingKeys.shift();
+		}
+		this._ackSeqNum = seqNum;
+		this._reconcile = true;
+	}
+
+	private replayKeys() : void {
+		this._pendingKeys.forEach((key, i) => {
+			const keyMsg = Object.assign({}, key);
+			keyMsg.K = Util.arrayToString(key.K);
+			wasmUpdateKeys(this.id(), keyMsg);
+			// The server applies one input per tick after the acked state
+			wasmStepPlayer(this.id(), this._ackSeqNum + i + 1);
+		});
+	}
+
 	private updateKeys() : void {
 		if (this.sceneMap().has(playerSpace, this.id())) {
 			const keyMsg = this._keys.keyMsg(this._keySeqNum);
@@ -226,6 +282,10 @@ class Game {
 
 		let player : RenderPlayer = this.sceneMap().getAsAny(playerSpace, this.id());
 		wasmSetData(playerSpace, this.id(), player.data());
+		if (this._reconcile) {
+			this.replayKeys();
+			this._reconcile = false;
+		}
 		this.updateKeys();
 		player.setData(JSON.parse(wasmGetData(playerSpace, this.id())));
 		player.update();
@@ -240,7 +300,7 @@ class Game {
 	private initLevel(msg : { [k: string]: any }) : void {
 		this.sceneMap().clearObjects();
 
-		const level = JSON.parse(wasmLoadLevel(msg.L));
+		const level = JSON.parse(wasmLoadLevel(msg.L, msg.P, msg.H));
 		for (const [stringSpace, objects] of Object.entries(level.Os) as [string, any]) {
 			for (const [stringId, data] of Object.entries(objects) as [string, any]) {
 				const space = Number(stringSpace);
@@ -255,6 +315,10 @@ class Game {
 
 	private updateCamera() : void {
 		if (!this.hasId()) return;
+		if (this.killcam().playing()) {
+			renderer.setCameraAnchor(this.killcam().anchor());
+			return;
+		}
 		if (!this.sceneMap().has(playerSpace, this.id())) return;
 
 		const player : RenderPlayer = this.sceneMap().getAsAny(playerSpace, this.id());
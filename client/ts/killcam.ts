@@ -0,0 +1,113 @@
+import * as THREE from 'three';
+
+import { SceneComponent } from './scene_component.js'
+import { Util } from './util.js'
+
+// Plays back the last moments before a death from the killer's perspective
+export class Killcam extends SceneComponent {
+	private readonly _killerMaterial = new THREE.MeshBasicMaterial( {color: 0xff4444, transparent: true, opacity: 0.6 });
+	private readonly _victimMaterial = new THREE.MeshBasicMaterial( {color: 0xffffff, transparent: true, opacity: 0.6 });
+	private readonly _projectileMaterial = new THREE.MeshBasicMaterial( {color: 0xffff44, transparent: true, opacity: 0.8 });
+	private readonly _playerGeometry = new THREE.BoxGeometry(0.8, 1.44, 0.8);
+	private readonly _projectileGeometry = new THREE.SphereGeometry(0.15, 8, 8);
+
+	private _killer : number;
+	private _frames : Array<any>;
+	private _started : number;
+	private _duration : number;
+
+	private _meshes : Map<string, THREE.Mesh>;
+	private _anchor : THREE.Vector3;
+
+	constructor() {
+		super();
+
+		this._killer = -1;
+		this._frames = new Array();
+		this._started = 0;
+		this._duration = 0;
+
+		this._meshes = new Map<string, THREE.Mesh>();
+		this._anchor = new THREE.Vector3();
+	}
+
+	play(msg : { [k: string]: any }) : void {
+		if (!Util.defined(msg.Fs) || msg.Fs.length === 0) {
+			return;
+		}
+
+		this._killer = msg.K;
+		this._frames = msg.Fs;
+		this._started = Date.now();
+		this._duration = msg.D;
+	}
+
+	playing() : boolean {
+		return this._frames.length > 0 && Date.now() - this._started < this._duration;
+	}
+
+	// Killer position in the current frame
+	anchor() : THREE.Vector3 { return this._anchor; }
+
+	override update() : void {
+		super.update();
+
+		if (!this.playing()) {
+			if (this._frames.length > 0) {
+				this.stop();
+			}
+			return;
+		}
+
+		const weight = (Date.now() - this._started) / this._duration;
+		const frame = this._frames[Math.min(this._frames.length - 1, Math.floor(weight * this._frames.length))];
+
+		const seen = new Set<string>();
+		for (const [stringSpace, objects] of Object.entries(frame.Os) as [string, any]) {
+			for (const [stringId, object] of Object.entries(objects) as [string, any]) {
+				const space = Number(stringSpace);
+				const id = Number(stringId);
+				const key = space + "," + id;
+
+				seen.add(key);
+				const mesh = this.getMesh(key, space, id);
+				mesh.position.set(object.P.X, object.P.Y, 0);
+				mesh.visible = true;
+
+				if (space === playerSpace && id === this._killer) {
+					this._anchor.set(object.P.X, object.P.Y, 0);
+				}
+			}
+		}
+
+		this._meshes.forEach((mesh, key) => {
+			if (!seen.has(key)) {
+				mesh.visible = false;
+			}
+		});
+	}
+
+	private stop() : void {
+		this._frames = new Array();
+		this._meshes.forEach((mesh) => {
+			this._scene.remove(mesh);
+		});
+		this._meshes.clear();
+	}
+
+	private getMesh(key : string, space : number, id : number) : THREE.Mesh {
+		if (this._meshes.has(key)) {
+			return this._meshes.get(key);
+		}
+
+		let mesh;
+		if (space === playerSpace) {
+			mesh = new THREE.Mesh(this._playerGeometry, id === this._killer ? this._killerMaterial : this._victimMaterial);
+		} else {
+			mesh = new THREE.Mesh(this._projectileGeometry, this._projectileMaterial);
+		}
+		this._meshes.set(key, mesh);
+		this._scene.add(mesh);
+		return mesh;
+	}
+}
\ No newline at end of file
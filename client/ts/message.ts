@@ -19,6 +19,7 @@ export class Message {
 		[attributesProp, OverwriteMethod.MERGE_AND_REPLACE],
 		[byteAttributesProp, OverwriteMethod.MERGE_AND_REPLACE],
 		[keysProp, OverwriteMethod.REPLACE_ALL],
+		[powerUpsProp, OverwriteMethod.REPLACE_ALL],
 	]);
 
 	private _data : Map<number, any>;
@@ -16,6 +16,18 @@ export class Pinger {
 		this._lastPingNumber = 0;
 
 		connection.addHandler(pingType, (msg : any) => {
+			// Echo the server's own pings, which it uses for lag compensation
+			if (msg.E) {
+				connection.sendData({
+					T: pingType,
+					Ping : {
+						S: msg.S,
+						E: true,
+					}
+				});
+				return;
+			}
+
 			const index = msg.S % this._maxPings;
 			this._pings[index] = Date.now() - this._pingTimes[index];
 
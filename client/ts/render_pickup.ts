@@ -6,22 +6,42 @@ import { RenderObject } from './render_object.js'
 import { renderer } from './renderer.js'
 
 export class RenderPickup extends RenderObject {
+	private readonly _healthMaterial = new THREE.MeshStandardMaterial( {color: 0x44ff44 } );
+	private readonly _armorMaterial = new THREE.MeshStandardMaterial( {color: 0x4444ff } );
+	private readonly _defaultMaterial = new THREE.MeshStandardMaterial( {color: 0xffff44 } );
 
 	constructor(space : number, id : number) {
 		super(space, id);
 	}
 
 	override ready() : boolean {
-		return super.ready() && this.hasByteAttribute(typeByteAttribute);
+		return super.ready() && this.hasByteAttribute(pickupByteAttribute);
 	}
 
 	override initialize() : void {
 		super.initialize();
 
-		const model = loader.getWeaponModel(this.byteAttribute(typeByteAttribute));
-		loader.load(model, (mesh) => {
-			this.setMesh(mesh);
-		});
+		if (this.byteAttribute(pickupByteAttribute) === weaponPickup) {
+			const model = loader.getWeaponModel(this.byteAttribute(typeByteAttribute));
+			loader.load(model, (mesh) => {
+				this.setMesh(mesh);
+			});
+			return;
+		}
+
+		let material = this._defaultMaterial;
+		if (this.byteAttribute(pickupByteAttribute) === healthPickup) {
+			material = this._healthMaterial;
+		} else if (this.byteAttribute(pickupByteAttribute) === armorPickup) {
+			material = this._armorMaterial;
+		}
+
+		const dim = this.dim();
+		const group = new THREE.Group();
+		const box = new THREE.Mesh(new THREE.BoxGeometry(dim.x, dim.y, dim.x), material);
+		box.name = "mesh";
+		group.add(box);
+		this.setMesh(group);
 	}
 
 	override setMesh(mesh : THREE.Object3D) {
@@ -44,6 +64,9 @@ export class RenderPickup extends RenderObject {
 			return;
 		}
 
+		// Hidden while waiting to respawn, timerByteAttribute has the seconds remaining
+		this.mesh().visible = !this.attribute(hiddenAttribute);
+
 		this.mesh().rotation.y += 1.2 * this.timestep();
 		this.mesh().rotation.x += 0.6 * this.timestep();
 	}
@@ -8,6 +8,7 @@ export enum SceneComponentType {
 	WEATHER = 2,
 	PARTICLES = 3,
 	DECORATION = 4,
+	KILLCAM = 5,
 }
 
 export abstract class SceneComponent {
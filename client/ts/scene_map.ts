@@ -1,6 +1,7 @@
 import * as THREE from 'three';
 
 import { Decoration } from './decoration.js'
+import { Killcam } from './killcam.js'
 import { LightBuffer } from './light_buffer.js'
 import { Lighting } from './lighting.js'
 import { Particles } from './particles.js'
@@ -37,6 +38,7 @@ export class SceneMap {
 		this.addComponent(SceneComponentType.WEATHER, new Weather());
 		this.addComponent(SceneComponentType.PARTICLES, new Particles());
 		this.addComponent(SceneComponentType.DECORATION, new Decoration());
+		this.addComponent(SceneComponentType.KILLCAM, new Killcam());
 
 		this._lightBuffer = new LightBuffer(this._scene);
 	}
@@ -132,9 +134,7 @@ export class SceneMap {
 	delete(space : number, id : number) : void {
 		const map = this.getMap(space);
 		if (map.has(id)) {
-			map.get(id).delete();
-			this.removeMesh(map.get(id).mesh());
-			map.delete(id);
+			this.hide(space, id);
 
 			if (!this._deleted.has(space)) {
 				this._deleted.set(space, new Set());
@@ -143,6 +143,16 @@ export class SceneMap {
 		}
 	}
 
+	// Remove the object without deleting it, so it can be added again later
+	hide(space : number, id : number) : void {
+		const map = this.getMap(space);
+		if (map.has(id)) {
+			map.get(id).delete();
+			this.removeMesh(map.get(id).mesh());
+			map.delete(id);
+		}
+	}
+
 	removeMesh(object : THREE.Object3D) : void {
 		this._scene.remove(object);
 	}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"time"
+)
+
+// Source of time for the simulation. Step is called once per simulation step by the Grid.
+type Clock interface {
+	Now() time.Time
+	Step(tick SeqNumType, timestep time.Duration)
+}
+
+// Advances by a fixed timestep every simulation step
+type TickClock struct {
+	now time.Time
+}
+
+func NewTickClock() *TickClock {
+	return &TickClock {
+		now: SimTime(0, frameTime),
+	}
+}
+
+func (c *TickClock) Now() time.Time { return c.now }
+func (c *TickClock) Step(tick SeqNumType, timestep time.Duration) {
+	c.now = SimTime(tick, timestep)
+}
+
+// Wall clock time, for pacing the simulation rather than running it
+type RealClock struct {}
+
+func NewRealClock() *RealClock {
+	return &RealClock{}
+}
+
+func (c *RealClock) Now() time.Time { return time.Now() }
+func (c *RealClock) Step(tick SeqNumType, timestep time.Duration) {}
+
+// Advances by the grid's timestep each step like TickClock, but can also be moved by hand so tests
+// can put the simulation at an exact time
+type ManualClock struct {
+	now time.Time
+}
+
+func NewManualClock() *ManualClock {
+	return &ManualClock {
+		now: simEpoch,
+	}
+}
+
+func (c *ManualClock) Now() time.Time { return c.now }
+func (c *ManualClock) Step(tick SeqNumType, timestep time.Duration) {
+	c.now = c.now.Add(timestep)
+}
+
+func (c *ManualClock) Advance(duration time.Duration) {
+	c.now = c.now.Add(duration)
+}
\ No newline at end of file
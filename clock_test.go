@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestGame(clock Clock) *Game {
+	game := NewGame(NewGameConfig(), clock)
+	game.loadLevel(testLevel)
+	return game
+}
+
+// Steps like the room does, which is when deleted objects are removed
+func step(game *Game) {
+	game.updateState(game.seqNum + 1, frameTime)
+	game.createGameUpdateMsg()
+}
+
+// Timers compare elapsed time with >=, so they finish on the first step at or past the duration
+func ticksFor(duration time.Duration) int {
+	return int((duration + frameTime - 1) / frameTime)
+}
+
+func stepTicks(game *Game, ticks int) {
+	for i := 0; i < ticks; i++ {
+		step(game)
+	}
+}
+
+func TestManualClockStep(t *testing.T) {
+	clock := NewManualClock()
+	game := newTestGame(clock)
+
+	start := clock.Now()
+	for i := 0; i < 10; i++ {
+		step(game)
+	}
+	if elapsed := clock.Now().Sub(start); elapsed != 10 * frameTime {
+		t.Errorf("Clock moved %v in 10 steps, want %v", elapsed, 10 * frameTime)
+	}
+
+	clock.Advance(time.Second)
+	if elapsed := clock.Now().Sub(start); elapsed != 10 * frameTime + time.Second {
+		t.Errorf("Clock moved %v after advancing, want %v", elapsed, 10 * frameTime + time.Second)
+	}
+}
+
+func TestManualClockRocketExplodes(t *testing.T) {
+	clock := NewManualClock()
+	game := newTestGame(clock)
+
+	// High above the level so it only expires
+	rocket := game.add(NewObjectInit(game.grid.NextSpacedId(rocketSpace), NewVec2(20, 40), NewVec2(0.5, 0.5)))
+	if rocket == nil {
+		t.Fatalf("Failed to add rocket")
+	}
+
+	// Its TTL starts on the first step
+	step(game)
+	stepTicks(game, ticksFor(time.Second) - 1)
+	if !game.has(rocket.GetSpacedId()) {
+		t.Fatalf("Rocket exploded before its TTL")
+	}
+	if len(game.grid.GetObjects(explosionSpace)) != 0 {
+		t.Errorf("Explosion created before the rocket's TTL")
+	}
+
+	step(game)
+	if game.has(rocket.GetSpacedId()) {
+		t.Errorf("Rocket still exists after its TTL")
+	}
+	if len(game.grid.GetObjects(explosionSpace)) != 1 {
+		t.Errorf("Rocket didn't explode after its TTL")
+	}
+}
+
+func TestManualClockRespawn(t *testing.T) {
+	clock := NewManualClock()
+	game := newTestGame(clock)
+
+	player := game.addPlayer(1).(*Player)
+	player.Die()
+
+	// The death timer starts on the first step
+	step(game)
+	if !player.HasAttribute(deadAttribute) {
+		t.Fatalf("Player isn't dead after dying")
+	}
+
+	stepTicks(game, ticksFor(deathDuration) - 1)
+	if !player.HasAttribute(deadAttribute) {
+		t.Errorf("Player respawned before the death timer ended")
+	}
+
+	step(game)
+	if player.HasAttribute(deadAttribute) {
+		t.Errorf("Player didn't respawn after the death timer")
+	}
+	if player.GetHealth() != player.GetMaxHealth() {
+		t.Errorf("Player respawned with %d health, want %d", player.GetHealth(), player.GetMaxHealth())
+	}
+}
\ No newline at end of file
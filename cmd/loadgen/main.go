@@ -0,0 +1,193 @@
+// Command loadgen connects scripted headless clients to a server and reports per-room stats.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/bchoi12/blockdudes3/gameclient"
+)
+
+const (
+	frameTime time.Duration = 16 * time.Millisecond
+	pingTime time.Duration = 500 * time.Millisecond
+	// Longer than the server waits for WebRTC before falling back to the websocket
+	readyTimeout time.Duration = 15 * time.Second
+)
+
+var host = flag.String("host", "localhost:8080", "server address")
+var secure = flag.Bool("secure", false, "use wss")
+var numClients = flag.Int("clients", 8, "total number of clients")
+var numRooms = flag.Int("rooms", 2, "number of rooms to spread clients across")
+var roomPrefix = flag.String("prefix", "loadgen", "room name prefix")
+var useWebRTC = flag.Bool("webrtc", true, "negotiate a WebRTC data channel")
+var duration = flag.Duration("duration", 30 * time.Second, "how long to run, 0 to run forever")
+var reportTime = flag.Duration("report", 5 * time.Second, "how often to print stats")
+var spawnDelay = flag.Duration("spawn", 100 * time.Millisecond, "delay between client connections")
+
+type Bot struct {
+	client *gameclient.Client
+	room string
+
+	keys map[gameclient.Key]bool
+	dir gameclient.Vec2
+}
+
+func NewBot(client *gameclient.Client, room string) *Bot {
+	return &Bot {
+		client: client,
+		room: room,
+		keys: make(map[gameclient.Key]bool),
+		dir: gameclient.Vec2 { X: 1, Y: 0 },
+	}
+}
+
+// Randomly wanders, jumps and shoots
+func (b *Bot) updateKeys() {
+	if rand.Float64() < 0.02 {
+		b.keys[gameclient.LeftKey] = rand.Float64() < 0.5
+		b.keys[gameclient.RightKey] = !b.keys[gameclient.LeftKey]
+	}
+	b.keys[gameclient.JumpKey] = rand.Float64() < 0.05
+	b.keys[gameclient.MouseClick] = rand.Float64() < 0.3
+
+	if rand.Float64() < 0.05 {
+		b.dir.X = rand.Float64() * 2 - 1
+		b.dir.Y = rand.Float64() * 2 - 1
+	}
+}
+
+func (b *Bot) run(done <-chan struct{}) {
+	frame := time.NewTicker(frameTime)
+	ping := time.NewTicker(pingTime)
+	defer frame.Stop()
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-b.client.Closed():
+			log.Printf("%s: client %d disconnected", b.room, b.client.Id())
+			return
+		case <-ping.C:
+			b.client.Ping()
+		case <-frame.C:
+			b.updateKeys()
+
+			keys := make([]gameclient.Key, 0, len(b.keys))
+			for key, pressed := range(b.keys) {
+				if pressed {
+					keys = append(keys, key)
+				}
+			}
+			if err := b.client.SendKeys(keys, b.dir, b.dir); err != nil {
+				log.Printf("%s: error sending keys: %v", b.room, err)
+			}
+		}
+	}
+}
+
+func report(bots []*Bot) {
+	rooms := make(map[string][]gameclient.StatsSnapshot)
+	for _, bot := range(bots) {
+		rooms[bot.room] = append(rooms[bot.room], bot.client.Stats().Flush())
+	}
+
+	for i := 0; i < *numRooms; i++ {
+		room := roomName(i)
+		snapshots := rooms[room]
+		if len(snapshots) == 0 {
+			continue
+		}
+
+		var tickRate, avgBytes float64
+		var maxBytes int
+		var latency time.Duration
+		for _, snapshot := range(snapshots) {
+			tickRate += snapshot.TickRate()
+			avgBytes += snapshot.AvgBytes()
+			latency += snapshot.Latency
+			if snapshot.MaxBytes > maxBytes {
+				maxBytes = snapshot.MaxBytes
+			}
+		}
+		n := len(snapshots)
+		fmt.Printf("%s: clients=%d tick=%.1f/s avg_msg=%.0fB max_msg=%dB latency=%v\n",
+			room, n, tickRate / float64(n), avgBytes / float64(n), maxBytes, (latency / time.Duration(n)).Round(time.Millisecond))
+	}
+}
+
+func roomName(i int) string {
+	return fmt.Sprintf("%s%d", *roomPrefix, i)
+}
+
+func main() {
+	flag.Parse()
+	if *numRooms <= 0 || *numClients <= 0 {
+		log.Fatal("clients and rooms must be positive")
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	bots := make([]*Bot, 0, *numClients)
+
+	for i := 0; i < *numClients; i++ {
+		room := roomName(i % *numRooms)
+		client, err := gameclient.Dial(gameclient.Options {
+			Host: *host,
+			Room: room,
+			Name: fmt.Sprintf("load%d", i),
+			Secure: *secure,
+			WebRTC: *useWebRTC,
+		})
+		if err != nil {
+			log.Printf("%s: failed to connect client %d: %v", room, i, err)
+			continue
+		}
+		defer client.Close()
+
+		if err := client.WaitReady(readyTimeout); err != nil {
+			log.Printf("%s: client %d not ready: %v", room, i, err)
+			continue
+		}
+
+		bot := NewBot(client, room)
+		bots = append(bots, bot)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bot.run(done)
+		}()
+
+		time.Sleep(*spawnDelay)
+	}
+	log.Printf("Connected %d/%d clients across %d rooms", len(bots), *numClients, *numRooms)
+
+	// Discard stats from connecting
+	for _, bot := range(bots) {
+		bot.client.Stats().Flush()
+	}
+
+	reportTicker := time.NewTicker(*reportTime)
+	defer reportTicker.Stop()
+	var timeout <-chan time.Time
+	if *duration > 0 {
+		timeout = time.After(*duration)
+	}
+
+	for {
+		select {
+		case <-reportTicker.C:
+			report(bots)
+		case <-timeout:
+			close(done)
+			wg.Wait()
+			return
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,628 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+	"math"
+)
+
+const (
+	// Never the first byte of a msgpack message, so clients can tell the two encodings apart
+	compactMagic byte = 0xc1
+
+	// Room around the walls for players jumping or falling off and projectiles in flight
+	boundsMargin float64 = 16
+
+	// Fixed-point scales for int16 motion and int8 direction components
+	motionScale float64 = 64
+	dirScale float64 = 127
+
+	// Set on a prop when its value doesn't fit the schema. Vec2 values are sent as float32s, anything else with msgpack.
+	exactPropFlag Prop = 0x40
+	rawPropFlag Prop = 0x80
+	propMask Prop = 0x3f
+
+	// In place of the prop count for objects whose props don't fit the tags, which are packed with msgpack instead
+	rawPropMapMarker byte = 0xff
+)
+
+type propKind uint8
+const (
+	unknownKind propKind = iota
+
+	boolKind
+	scoreKind
+	seqNumKind
+
+	posKind
+	motionKind
+	dirKind
+	exactKind
+
+	attributesKind
+	byteAttributesKind
+	powerUpsKind
+	keysKind
+	spacedIdKind
+	propMapsKind
+)
+
+// How each prop is encoded in compact messages. Props not listed here fall back to msgpack.
+var propSchema = map[Prop]propKind {
+	deletedProp: boolKind,
+	hiddenProp: boolKind,
+
+	attributesProp: attributesKind,
+	byteAttributesProp: byteAttributesKind,
+
+	dimProp: exactKind,
+	posProp: posKind,
+	velProp: motionKind,
+	accProp: motionKind,
+	jerkProp: motionKind,
+	dirProp: dirKind,
+
+	keysProp: keysKind,
+	ownerProp: spacedIdKind,
+	targetProp: spacedIdKind,
+	hitsProp: propMapsKind,
+
+	killProp: scoreKind,
+	deathProp: scoreKind,
+	powerUpsProp: powerUpsKind,
+	keySeqNumProp: seqNumKind,
+}
+
+// Region that positions are quantized to 16 bits within, in whole units so it's cheap to send with every message
+type Bounds struct {
+	MinX int16
+	MinY int16
+	MaxX int16
+	MaxY int16
+}
+
+func NewBounds(grid *Grid) Bounds {
+	walls := grid.GetObjects(wallSpace)
+	if len(walls) == 0 {
+		return Bounds {
+			MinX: int16(-boundsMargin),
+			MinY: int16(-boundsMargin),
+			MaxX: int16(boundsMargin),
+			MaxY: int16(boundsMargin),
+		}
+	}
+
+	min := NewVec2(math.Inf(1), math.Inf(1))
+	max := NewVec2(math.Inf(-1), math.Inf(-1))
+	for _, wall := range(walls) {
+		pos := wall.Pos()
+		dim := wall.Dim()
+		min.X = Min(min.X, pos.X - dim.X / 2)
+		min.Y = Min(min.Y, pos.Y - dim.Y / 2)
+		max.X = Max(max.X, pos.X + dim.X / 2)
+		max.Y = Max(max.Y, pos.Y + dim.Y / 2)
+	}
+
+	return Bounds {
+		MinX: int16(Clamp(math.MinInt16, math.Floor(min.X - boundsMargin), math.MaxInt16 - 1)),
+		MinY: int16(Clamp(math.MinInt16, math.Floor(min.Y - boundsMargin), math.MaxInt16 - 1)),
+		MaxX: int16(Clamp(math.MinInt16 + 1, math.Ceil(max.X + boundsMargin), math.MaxInt16)),
+		MaxY: int16(Clamp(math.MinInt16 + 1, math.Ceil(max.Y + boundsMargin), math.MaxInt16)),
+	}
+}
+
+func (b Bounds) quantize(pos Vec2) (uint16, uint16, bool) {
+	x := (pos.X - float64(b.MinX)) / float64(int(b.MaxX) - int(b.MinX))
+	y := (pos.Y - float64(b.MinY)) / float64(int(b.MaxY) - int(b.MinY))
+	if x < 0 || x > 1 || y < 0 || y > 1 {
+		return 0, 0, false
+	}
+	return uint16(math.Round(x * math.MaxUint16)), uint16(math.Round(y * math.MaxUint16)), true
+}
+
+func (b Bounds) dequantize(x uint16, y uint16) Vec2 {
+	return NewVec2(
+		float64(b.MinX) + float64(x) / math.MaxUint16 * float64(int(b.MaxX) - int(b.MinX)),
+		float64(b.MinY) + float64(y) / math.MaxUint16 * float64(int(b.MaxY) - int(b.MinY)))
+}
+
+// Schema-based alternative to Pack for GameStateMsg. Positions are quantized within bounds, motion to
+// fixed-point int16 and directions to int8, so values only round trip approximately.
+func PackCompact(msg GameStateMsg, bounds Bounds) []byte {
+	w := &codecWriter {}
+	w.buf.WriteByte(compactMagic)
+	w.buf.WriteByte(byte(msg.T))
+	w.writeUvarint(uint64(msg.S))
+	w.buf.WriteByte(msg.C)
+	for _, v := range([]int16{bounds.MinX, bounds.MinY, bounds.MaxX, bounds.MaxY}) {
+		w.writeUint16(uint16(v))
+	}
+
+	w.writeUvarint(uint64(len(msg.Ms)))
+	for _, m := range(msg.Ms) {
+		w.writeUvarint(uint64(len(m)))
+		w.buf.WriteString(m)
+	}
+
+	w.writeUvarint(uint64(len(msg.Os)))
+	for space, objects := range(msg.Os) {
+		w.buf.WriteByte(byte(space))
+		w.writeUvarint(uint64(len(objects)))
+		for id, props := range(objects) {
+			w.writeUvarint(uint64(id))
+			w.writePropMap(props, bounds)
+		}
+	}
+	return w.buf.Bytes()
+}
+
+// Bytes an object adds to a compact message, not counting the header for its space
+func CompactObjectSize(id IdType, props PropMap, bounds Bounds) int {
+	w := &codecWriter {}
+	w.writeUvarint(uint64(id))
+	w.writePropMap(props, bounds)
+	return w.buf.Len()
+}
+
+func IsCompact(b []byte) bool {
+	return len(b) > 0 && b[0] == compactMagic
+}
+
+func UnpackCompact(b []byte) (GameStateMsg, error) {
+	msg := GameStateMsg{}
+	if !IsCompact(b) {
+		return msg, errors.New("UnpackCompact: missing header")
+	}
+
+	r := &codecReader { b: b, i: 1 }
+	msg.T = MessageType(r.readByte())
+	msg.S = SeqNumType(r.readUvarint())
+	msg.C = r.readByte()
+
+	bounds := Bounds {
+		MinX: int16(r.readUint16()),
+		MinY: int16(r.readUint16()),
+		MaxX: int16(r.readUint16()),
+		MaxY: int16(r.readUint16()),
+	}
+	if r.err == nil && (bounds.MaxX <= bounds.MinX || bounds.MaxY <= bounds.MinY) {
+		return msg, fmt.Errorf("UnpackCompact: invalid bounds %+v", bounds)
+	}
+
+	if numMs := r.readCount(); numMs > 0 {
+		msg.Ms = make([]string, numMs)
+		for i := range(msg.Ms) {
+			msg.Ms[i] = string(r.readBytes(r.readCount()))
+		}
+	}
+
+	msg.Os = make(ObjectPropMap)
+	numSpaces := r.readCount()
+	for i := 0; i < numSpaces && r.err == nil; i++ {
+		space := SpaceType(r.readByte())
+		numObjects := r.readCount()
+		msg.Os[space] = make(SpacedPropMap)
+		for j := 0; j < numObjects && r.err == nil; j++ {
+			id := IdType(r.readUvarint())
+			msg.Os[space][id] = r.readPropMap(bounds)
+		}
+	}
+
+	if r.err != nil {
+		return msg, r.err
+	}
+	if r.i != len(r.b) {
+		return msg, fmt.Errorf("UnpackCompact: %d trailing bytes", len(r.b) - r.i)
+	}
+	return msg, nil
+}
+
+type codecWriter struct {
+	buf bytes.Buffer
+	scratch [binary.MaxVarintLen64]byte
+}
+
+func (w *codecWriter) writeUvarint(v uint64) {
+	n := binary.PutUvarint(w.scratch[:], v)
+	w.buf.Write(w.scratch[:n])
+}
+
+func (w *codecWriter) writeUint16(v uint16) {
+	binary.LittleEndian.PutUint16(w.scratch[:], v)
+	w.buf.Write(w.scratch[:2])
+}
+
+func (w *codecWriter) writeFloat32(v float64) {
+	binary.LittleEndian.PutUint32(w.scratch[:], math.Float32bits(float32(v)))
+	w.buf.Write(w.scratch[:4])
+}
+
+func (w *codecWriter) writeBool(v bool) {
+	if v {
+		w.buf.WriteByte(1)
+	} else {
+		w.buf.WriteByte(0)
+	}
+}
+
+func (w *codecWriter) writePropMap(props PropMap, bounds Bounds) {
+	if !fitsTags(props) {
+		w.buf.WriteByte(rawPropMapMarker)
+		b := Pack(props)
+		w.writeUvarint(uint64(len(b)))
+		w.buf.Write(b)
+		return
+	}
+
+	w.buf.WriteByte(byte(len(props)))
+	for prop, data := range(props) {
+		if !w.writeProp(prop, data, bounds) {
+			w.buf.WriteByte(byte(prop | rawPropFlag))
+			b := Pack(data)
+			w.writeUvarint(uint64(len(b)))
+			w.buf.Write(b)
+		}
+	}
+}
+
+// Whether every prop id fits under the tag flags and the count fits under the marker
+func fitsTags(props PropMap) bool {
+	if len(props) >= int(rawPropMapMarker) {
+		return false
+	}
+	for prop := range(props) {
+		if prop & propMask != prop {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns false if the value doesn't match the schema and nothing was written
+func (w *codecWriter) writeProp(prop Prop, data interface{}, bounds Bounds) bool {
+	switch propSchema[prop] {
+	case boolKind:
+		if v, ok := data.(bool); ok {
+			w.buf.WriteByte(byte(prop))
+			w.writeBool(v)
+			return true
+		}
+	case scoreKind:
+		if v, ok := data.(ScoreType); ok {
+			w.buf.WriteByte(byte(prop))
+			w.buf.WriteByte(byte(v))
+			return true
+		}
+	case seqNumKind:
+		if v, ok := data.(SeqNumType); ok {
+			w.buf.WriteByte(byte(prop))
+			w.writeUvarint(uint64(v))
+			return true
+		}
+	case posKind:
+		if v, ok := data.(Vec2); ok {
+			if x, y, ok := bounds.quantize(v); ok {
+				w.buf.WriteByte(byte(prop))
+				w.writeUint16(x)
+				w.writeUint16(y)
+			} else {
+				w.writeExact(prop, v)
+			}
+			return true
+		}
+	case motionKind:
+		if v, ok := data.(Vec2); ok {
+			x, y := math.Round(v.X * motionScale), math.Round(v.Y * motionScale)
+			if x >= math.MinInt16 && x <= math.MaxInt16 && y >= math.MinInt16 && y <= math.MaxInt16 {
+				w.buf.WriteByte(byte(prop))
+				w.writeUint16(uint16(int16(x)))
+				w.writeUint16(uint16(int16(y)))
+			} else {
+				w.writeExact(prop, v)
+			}
+			return true
+		}
+	case dirKind:
+		if v, ok := data.(Vec2); ok {
+			if Abs(v.X) <= 1 && Abs(v.Y) <= 1 {
+				w.buf.WriteByte(byte(prop))
+				w.buf.WriteByte(byte(int8(math.Round(v.X * dirScale))))
+				w.buf.WriteByte(byte(int8(math.Round(v.Y * dirScale))))
+			} else {
+				w.writeExact(prop, v)
+			}
+			return true
+		}
+	case exactKind:
+		if v, ok := data.(Vec2); ok {
+			w.buf.WriteByte(byte(prop))
+			w.writeFloat32(v.X)
+			w.writeFloat32(v.Y)
+			return true
+		}
+	case attributesKind:
+		if v, ok := data.(map[AttributeType]bool); ok {
+			var present, set uint64
+			for attribute, on := range(v) {
+				if attribute >= 64 {
+					return false
+				}
+				present |= 1 << attribute
+				if on {
+					set |= 1 << attribute
+				}
+			}
+			w.buf.WriteByte(byte(prop))
+			w.writeUvarint(present)
+			w.writeUvarint(set)
+			return true
+		}
+	case byteAttributesKind:
+		if v, ok := data.(map[ByteAttributeType]uint8); ok {
+			w.buf.WriteByte(byte(prop))
+			w.buf.WriteByte(byte(len(v)))
+			for attribute, value := range(v) {
+				w.buf.WriteByte(byte(attribute))
+				w.buf.WriteByte(value)
+			}
+			return true
+		}
+	case powerUpsKind:
+		if v, ok := data.(map[PowerUpType]uint8); ok {
+			w.buf.WriteByte(byte(prop))
+			w.buf.WriteByte(byte(len(v)))
+			for powerUp, seconds := range(v) {
+				w.buf.WriteByte(byte(powerUp))
+				w.buf.WriteByte(seconds)
+			}
+			return true
+		}
+	case keysKind:
+		if v, ok := data.(map[KeyType]bool); ok {
+			var present, set uint64
+			for key, down := range(v) {
+				if key >= 64 {
+					return false
+				}
+				present |= 1 << key
+				if down {
+					set |= 1 << key
+				}
+			}
+			w.buf.WriteByte(byte(prop))
+			w.writeUvarint(present)
+			w.writeUvarint(set)
+			return true
+		}
+	case spacedIdKind:
+		if v, ok := data.(SpacedId); ok {
+			w.buf.WriteByte(byte(prop))
+			w.buf.WriteByte(byte(v.S))
+			w.writeUvarint(uint64(v.Id))
+			return true
+		}
+	case propMapsKind:
+		if v, ok := data.([]PropMap); ok {
+			w.buf.WriteByte(byte(prop))
+			w.writeUvarint(uint64(len(v)))
+			for _, props := range(v) {
+				w.writePropMap(props, bounds)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (w *codecWriter) writeExact(prop Prop, v Vec2) {
+	w.buf.WriteByte(byte(prop | exactPropFlag))
+	w.writeFloat32(v.X)
+	w.writeFloat32(v.Y)
+}
+
+// Records the first error and returns zero values after it
+type codecReader struct {
+	b []byte
+	i int
+	err error
+}
+
+func (r *codecReader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *codecReader) readBytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.i + n > len(r.b) {
+		r.fail(errors.New("UnpackCompact: unexpected end of message"))
+		return nil
+	}
+	r.i += n
+	return r.b[r.i - n:r.i]
+}
+
+func (r *codecReader) readByte() byte {
+	if b := r.readBytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *codecReader) readUvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b[r.i:])
+	if n <= 0 {
+		r.fail(errors.New("UnpackCompact: bad varint"))
+		return 0
+	}
+	r.i += n
+	return v
+}
+
+// Counts can't exceed the remaining bytes, which stops garbage from causing huge allocations
+func (r *codecReader) readCount() int {
+	v := r.readUvarint()
+	if v > uint64(len(r.b) - r.i) {
+		r.fail(errors.New("UnpackCompact: count exceeds message"))
+		return 0
+	}
+	return int(v)
+}
+
+func (r *codecReader) readUint16() uint16 {
+	if b := r.readBytes(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *codecReader) readFloat32() float64 {
+	if b := r.readBytes(4); b != nil {
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	}
+	return 0
+}
+
+func (r *codecReader) readPropMap(bounds Bounds) PropMap {
+	numProps := int(r.readByte())
+	if numProps == int(rawPropMapMarker) {
+		return r.readRawPropMap()
+	}
+
+	props := make(PropMap)
+	for i := 0; i < numProps && r.err == nil; i++ {
+		tagged := Prop(r.readByte())
+		prop := tagged & propMask
+
+		if tagged & rawPropFlag != 0 {
+			props[prop] = r.readRaw()
+		} else if tagged & exactPropFlag != 0 {
+			props[prop] = NewVec2(r.readFloat32(), r.readFloat32())
+		} else {
+			props[prop] = r.readProp(prop, bounds)
+		}
+	}
+	return props
+}
+
+func (r *codecReader) readProp(prop Prop, bounds Bounds) interface{} {
+	switch propSchema[prop] {
+	case boolKind:
+		return r.readByte() != 0
+	case scoreKind:
+		return ScoreType(r.readByte())
+	case seqNumKind:
+		return SeqNumType(r.readUvarint())
+	case posKind:
+		return bounds.dequantize(r.readUint16(), r.readUint16())
+	case motionKind:
+		x := int16(r.readUint16())
+		y := int16(r.readUint16())
+		return NewVec2(float64(x) / motionScale, float64(y) / motionScale)
+	case dirKind:
+		x := int8(r.readByte())
+		y := int8(r.readByte())
+		return NewVec2(float64(x) / dirScale, float64(y) / dirScale)
+	case exactKind:
+		return NewVec2(r.readFloat32(), r.readFloat32())
+	case attributesKind:
+		attributes := make(map[AttributeType]bool)
+		present, set := r.readUvarint(), r.readUvarint()
+		for attribute := AttributeType(0); attribute < 64; attribute++ {
+			if present & (1 << attribute) != 0 {
+				attributes[attribute] = set & (1 << attribute) != 0
+			}
+		}
+		return attributes
+	case byteAttributesKind:
+		attributes := make(map[ByteAttributeType]uint8)
+		for n := int(r.readByte()); n > 0 && r.err == nil; n-- {
+			attribute := ByteAttributeType(r.readByte())
+			attributes[attribute] = r.readByte()
+		}
+		return attributes
+	case powerUpsKind:
+		powerUps := make(map[PowerUpType]uint8)
+		for n := int(r.readByte()); n > 0 && r.err == nil; n-- {
+			powerUp := PowerUpType(r.readByte())
+			powerUps[powerUp] = r.readByte()
+		}
+		return powerUps
+	case keysKind:
+		keys := make(map[KeyType]bool)
+		present, set := r.readUvarint(), r.readUvarint()
+		for key := KeyType(0); key < 64; key++ {
+			if present & (1 << key) != 0 {
+				keys[key] = set & (1 << key) != 0
+			}
+		}
+		return keys
+	case spacedIdKind:
+		space := SpaceType(r.readByte())
+		return Id(space, IdType(r.readUvarint()))
+	case propMapsKind:
+		n := r.readCount()
+		propMaps := make([]PropMap, 0, n)
+		for i := 0; i < n && r.err == nil; i++ {
+			propMaps = append(propMaps, r.readPropMap(bounds))
+		}
+		return propMaps
+	}
+
+	r.fail(fmt.Errorf("UnpackCompact: no schema for prop %d", prop))
+	return nil
+}
+
+func (r *codecReader) readRaw() interface{} {
+	b := r.readBytes(r.readCount())
+	if r.err != nil {
+		return nil
+	}
+
+	var v interface{}
+	if err := newRawDecoder(b).Decode(&v); err != nil {
+		r.fail(err)
+		return nil
+	}
+	return v
+}
+
+func (r *codecReader) readRawPropMap() PropMap {
+	b := r.readBytes(r.readCount())
+	if r.err != nil {
+		return nil
+	}
+
+	props := make(PropMap)
+	if err := newRawDecoder(b).Decode(&props); err != nil {
+		r.fail(err)
+		return nil
+	}
+	return props
+}
+
+// Nested maps can have integer keys, so use string keys like JSON would
+func newRawDecoder(b []byte) *msgpack.Decoder {
+	decoder := msgpack.NewDecoder(bytes.NewReader(b))
+	decoder.SetMapDecoder(func(d *msgpack.Decoder) (interface{}, error) {
+		untyped, err := d.DecodeUntypedMap()
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]interface{}, len(untyped))
+		for k, v := range(untyped) {
+			m[fmt.Sprint(k)] = v
+		}
+		return m, nil
+	})
+	return decoder
+}
\ No newline at end of file
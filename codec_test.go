@@ -0,0 +1,179 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func newTestCodecMsg() (GameStateMsg, Bounds) {
+	game := NewGame(NewGameConfig(), NewTickClock())
+	game.loadLevel(testLevel)
+	for id := IdType(1); id <= 8; id++ {
+		game.addPlayer(id)
+	}
+	for i := 0; i < 30; i++ {
+		game.updateState(game.seqNum + 1, frameTime)
+	}
+	return NewSnapshot(game, nil).Msg(), NewBounds(game.grid)
+}
+
+func vec2Near(a Vec2, b Vec2, tolerance float64) bool {
+	return Abs(a.X - b.X) <= tolerance && Abs(a.Y - b.Y) <= tolerance
+}
+
+func TestCompactRoundTrip(t *testing.T) {
+	bounds := Bounds { MinX: -16, MinY: -16, MaxX: 64, MaxY: 48 }
+	posTolerance := 80.0 / math.MaxUint16
+
+	props := PropMap {
+		deletedProp: true,
+		attributesProp: map[AttributeType]bool { groundedAttribute: true, deadAttribute: false },
+		byteAttributesProp: map[ByteAttributeType]uint8 { healthByteAttribute: 75 },
+		dimProp: NewVec2(0.8, 1.44),
+		posProp: NewVec2(12.34, 5.67),
+		velProp: NewVec2(-3.5, 10.25),
+		dirProp: NewVec2(0.6, -0.8),
+		keysProp: map[KeyType]bool { leftKey: true, jumpKey: false },
+		ownerProp: Id(playerSpace, 3),
+		hitsProp: []PropMap { PropMap { targetProp: Id(playerSpace, 4), posProp: NewVec2(1, 2) } },
+		killProp: ScoreType(7),
+		keySeqNumProp: SeqNumType(123456),
+	}
+	msg := GameStateMsg {
+		T: objectDataType,
+		S: 42,
+		C: 3,
+		Os: ObjectPropMap {
+			playerSpace: SpacedPropMap { 1: props },
+		},
+	}
+
+	decoded, err := UnpackCompact(PackCompact(msg, bounds))
+	if err != nil {
+		t.Fatalf("UnpackCompact: %v", err)
+	}
+	if decoded.T != msg.T || decoded.S != msg.S || decoded.C != msg.C {
+		t.Errorf("Header is %d/%d/%d, want %d/%d/%d", decoded.T, decoded.S, decoded.C, msg.T, msg.S, msg.C)
+	}
+
+	got := decoded.Os[playerSpace][1]
+	if len(got) != len(props) {
+		t.Fatalf("Decoded %d props, want %d", len(got), len(props))
+	}
+	if !vec2Near(got[posProp].(Vec2), props[posProp].(Vec2), posTolerance) {
+		t.Errorf("Position is %v, want %v", got[posProp], props[posProp])
+	}
+	if !vec2Near(got[velProp].(Vec2), props[velProp].(Vec2), 1 / motionScale) {
+		t.Errorf("Velocity is %v, want %v", got[velProp], props[velProp])
+	}
+	if !vec2Near(got[dirProp].(Vec2), props[dirProp].(Vec2), 1 / dirScale) {
+		t.Errorf("Direction is %v, want %v", got[dirProp], props[dirProp])
+	}
+	if !vec2Near(got[dimProp].(Vec2), props[dimProp].(Vec2), 1e-6) {
+		t.Errorf("Dimension is %v, want %v", got[dimProp], props[dimProp])
+	}
+
+	for _, prop := range([]Prop { deletedProp, attributesProp, byteAttributesProp, keysProp, ownerProp, killProp, keySeqNumProp }) {
+		if !reflect.DeepEqual(got[prop], props[prop]) {
+			t.Errorf("Prop %d is %v, want %v", prop, got[prop], props[prop])
+		}
+	}
+
+	hits, ok := got[hitsProp].([]PropMap)
+	if !ok || len(hits) != 1 || hits[0][targetProp] != Id(playerSpace, 4) {
+		t.Errorf("Hits are %v, want %v", got[hitsProp], props[hitsProp])
+	}
+}
+
+func TestCompactRoundTripGame(t *testing.T) {
+	msg, bounds := newTestCodecMsg()
+	posTolerance := float64(int(bounds.MaxX) - int(bounds.MinX)) / math.MaxUint16
+
+	decoded, err := UnpackCompact(PackCompact(msg, bounds))
+	if err != nil {
+		t.Fatalf("UnpackCompact: %v", err)
+	}
+
+	for space, objects := range(msg.Os) {
+		for id, props := range(objects) {
+			got, ok := decoded.Os[space][id]
+			if !ok {
+				t.Errorf("Object %v is missing", Id(space, id))
+				continue
+			}
+			if len(got) != len(props) {
+				t.Errorf("Object %v has %d props, want %d", Id(space, id), len(got), len(props))
+			}
+			if pos, ok := props[posProp].(Vec2); ok && !vec2Near(got[posProp].(Vec2), pos, posTolerance) {
+				t.Errorf("Object %v is at %v, want %v", Id(space, id), got[posProp], pos)
+			}
+		}
+	}
+}
+
+// Props without a tag are packed with msgpack instead of failing
+func TestCompactRawFallback(t *testing.T) {
+	bounds := Bounds { MinX: -16, MinY: -16, MaxX: 16, MaxY: 16 }
+	msg := GameStateMsg {
+		T: objectDataType,
+		S: 1,
+		Os: ObjectPropMap {
+			wallSpace: SpacedPropMap {
+				1: PropMap { Prop(70): "too big for the tag", deletedProp: false },
+				2: PropMap { initializedProp: true },
+			},
+		},
+	}
+
+	decoded, err := UnpackCompact(PackCompact(msg, bounds))
+	if err != nil {
+		t.Fatalf("UnpackCompact: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Os, msg.Os) {
+		t.Errorf("Decoded %v, want %v", decoded.Os, msg.Os)
+	}
+}
+
+func TestUnpackCompactTruncated(t *testing.T) {
+	msg, bounds := newTestCodecMsg()
+	b := PackCompact(msg, bounds)
+
+	for n := 0; n < len(b); n++ {
+		if _, err := UnpackCompact(b[:n]); err == nil {
+			t.Fatalf("UnpackCompact accepted %d of %d bytes", n, len(b))
+		}
+	}
+}
+
+func BenchmarkPackCompact(b *testing.B) {
+	msg, bounds := newTestCodecMsg()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		PackCompact(msg, bounds)
+	}
+	b.ReportMetric(float64(len(PackCompact(msg, bounds))), "bytes/msg")
+}
+
+func BenchmarkUnpackCompact(b *testing.B) {
+	msg, bounds := newTestCodecMsg()
+	packed := PackCompact(msg, bounds)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := UnpackCompact(packed); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPack(b *testing.B) {
+	msg, _ := newTestCodecMsg()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Pack(&msg)
+	}
+	b.ReportMetric(float64(len(Pack(&msg))), "bytes/msg")
+}
\ No newline at end of file
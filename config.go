@@ -0,0 +1,140 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	defaultMaxHealth int = 100
+	defaultRegenDelay time.Duration = 5 * time.Second
+	defaultRegenRate float64 = 10
+
+	// Cap on how far shots are rewound to compensate for the shooter's latency
+	defaultMaxRewind time.Duration = 200 * time.Millisecond
+)
+
+// Sent to clients so prediction uses the same values
+type PhysicsConfig struct {
+	Gravity float64
+	DownAcc float64
+	SideAcc float64
+	TurnMultiplier float64
+
+	MaxUpwardVel float64
+	MaxHorizontalVel float64
+	MaxDownwardVel float64
+	MaxVelMultiplier float64
+	MaxSpeed float64
+	KnockbackForceSquared float64
+
+	JumpVel float64
+
+	Friction float64
+	KnockbackFriction float64
+	AirResistance float64
+}
+
+func NewPhysicsConfig() PhysicsConfig {
+	return PhysicsConfig {
+		Gravity: gravityAcc,
+		DownAcc: downAcc,
+		SideAcc: rightAcc,
+		TurnMultiplier: turnMultiplier,
+
+		MaxUpwardVel: maxUpwardVel,
+		MaxHorizontalVel: maxHorizontalVel,
+		MaxDownwardVel: maxDownwardVel,
+		MaxVelMultiplier: maxVelMultiplier,
+		MaxSpeed: maxSpeed,
+		KnockbackForceSquared: knockbackForceSquared,
+
+		JumpVel: jumpVel,
+
+		Friction: friction,
+		KnockbackFriction: knockbackFriction,
+		AirResistance: airResistance,
+	}
+}
+
+// Per-room settings that affect the simulation
+type GameConfig struct {
+	mutators []string
+	physics PhysicsConfig
+	maxHealth int
+
+	loadout WeaponType
+	weaponPickups bool
+	damageMultiplier float64
+	headScale float64
+
+	regen bool
+	regenDelay time.Duration
+	regenRate float64
+
+	armorAbsorption float64
+	// Recharging shield on top of health, disabled when 0
+	maxShield int
+	shieldDelay time.Duration
+	shieldRate float64
+
+	maxRewind time.Duration
+}
+
+func NewGameConfig() GameConfig {
+	return GameConfig {
+		mutators: make([]string, 0),
+		physics: NewPhysicsConfig(),
+		maxHealth: defaultMaxHealth,
+
+		loadout: unknownWeapon,
+		weaponPickups: true,
+		damageMultiplier: 1,
+		headScale: 1,
+
+		// Off unless the room asks for it with regen=1
+		regen: false,
+		regenDelay: defaultRegenDelay,
+		regenRate: defaultRegenRate,
+
+		armorAbsorption: defaultArmorAbsorption,
+		maxShield: 0,
+		shieldDelay: 0,
+		shieldRate: 0,
+
+		maxRewind: defaultMaxRewind,
+	}
+}
+
+func (gc *GameConfig) AddMutator(name string) { gc.mutators = append(gc.mutators, name) }
+func (gc *GameConfig) SetPhysics(physics PhysicsConfig) { gc.physics = physics }
+func (gc *GameConfig) SetMaxHealth(maxHealth int) { gc.maxHealth = maxHealth }
+func (gc *GameConfig) SetRegen(regen bool) { gc.regen = regen }
+func (gc *GameConfig) SetRegenDelay(delay time.Duration) { gc.regenDelay = delay }
+func (gc *GameConfig) SetRegenRate(rate float64) { gc.regenRate = rate }
+func (gc *GameConfig) SetLoadout(weaponType WeaponType) { gc.loadout = weaponType }
+func (gc *GameConfig) SetWeaponPickups(weaponPickups bool) { gc.weaponPickups = weaponPickups }
+func (gc *GameConfig) SetDamageMultiplier(multiplier float64) { gc.damageMultiplier = multiplier }
+func (gc *GameConfig) SetHeadScale(scale float64) { gc.headScale = scale }
+func (gc *GameConfig) SetArmorAbsorption(absorption float64) { gc.armorAbsorption = absorption }
+func (gc *GameConfig) SetShield(maxShield int, delay time.Duration, rate float64) {
+	gc.maxShield = maxShield
+	gc.shieldDelay = delay
+	gc.shieldRate = rate
+}
+func (gc *GameConfig) SetMaxRewind(maxRewind time.Duration) { gc.maxRewind = maxRewind }
+
+func (gc GameConfig) Mutators() []string { return gc.mutators }
+func (gc GameConfig) Physics() PhysicsConfig { return gc.physics }
+func (gc GameConfig) MaxHealth() int { return gc.maxHealth }
+func (gc GameConfig) Regen() bool { return gc.regen }
+func (gc GameConfig) RegenDelay() time.Duration { return gc.regenDelay }
+func (gc GameConfig) RegenRate() float64 { return gc.regenRate }
+func (gc GameConfig) Loadout() WeaponType { return gc.loadout }
+func (gc GameConfig) WeaponPickups() bool { return gc.weaponPickups }
+func (gc GameConfig) DamageMultiplier() float64 { return gc.damageMultiplier }
+func (gc GameConfig) HeadScale() float64 { return gc.headScale }
+func (gc GameConfig) ArmorAbsorption() float64 { return gc.armorAbsorption }
+func (gc GameConfig) MaxShield() int { return gc.maxShield }
+func (gc GameConfig) ShieldDelay() time.Duration { return gc.shieldDelay }
+func (gc GameConfig) ShieldRate() float64 { return gc.shieldRate }
+func (gc GameConfig) MaxRewind() time.Duration { return gc.maxRewind }
\ No newline at end of file
@@ -6,6 +6,7 @@ import (
 
 type Expiration struct {
 	enabled bool
+	started bool
 	startTime time.Time
 	ttl time.Duration
 }
@@ -13,14 +14,16 @@ type Expiration struct {
 func NewExpiration() Expiration {
 	return Expiration {
 		enabled: false,
+		started: false,
 		startTime: time.Time{},
 		ttl: 0,
 	}
 }
 
+// TTL starts counting from the next simulation step
 func (e *Expiration) SetTTL(ttl time.Duration) {
-	e.startTime = time.Now()
 	e.enabled = true
+	e.started = false
 	e.ttl = ttl
 }
 
@@ -28,9 +31,17 @@ func (e *Expiration) RemoveTTL() {
 	e.enabled = false
 }
 
-func (e Expiration) Expired() bool {
-	if !e.enabled || isWasm {
+func (e *Expiration) StartTTL(now time.Time) {
+	if !e.enabled || e.started {
+		return
+	}
+	e.started = true
+	e.startTime = now
+}
+
+func (e Expiration) Expired(now time.Time) bool {
+	if !e.enabled || !e.started || isWasm {
 		return false
 	}
-	return time.Now().Sub(e.startTime) >= e.ttl
+	return now.Sub(e.startTime) >= e.ttl
 }
\ No newline at end of file
@@ -55,13 +55,11 @@ func (e *Explosion) Hit(object Object, now time.Time) {
 }
 
 func (e *Explosion) UpdateState(grid *Grid, now time.Time) bool {
-	e.PrepareUpdate(now)
-
 	if isWasm {
 		return true
 	}
 
-	if e.Expired() {
+	if e.Expired(now) {
 		grid.Delete(e.GetSpacedId())
 	}
 
@@ -8,13 +8,15 @@ type Game struct {
 	grid *Grid
 	level LevelIdType
 	seqNum SeqNumType
+	navGraph *NavGraph
 }
 
-func NewGame() *Game {
+func NewGame(config GameConfig, clock Clock) *Game {
 	game := &Game {
-		grid: NewGrid(4, 4),
+		grid: NewGrid(4, 4, config, clock),
 		level: unknownLevel,
 		seqNum: 0,
+		navGraph: nil,
 	}
 	return game
 }
@@ -27,6 +29,10 @@ func (g *Game) add(init Init) Object {
 	return object
 }
 
+func (g *Game) addPlayer(id IdType) Object {
+	return g.add(NewObjectInit(Id(playerSpace, id), NewVec2(5, 5), NewVec2(0.8, 1.44)))
+}
+
 func (g *Game) has(sid SpacedId) bool {
 	return g.grid.Has(sid)
 }
@@ -53,6 +59,34 @@ func (g *Game) setData(sid SpacedId, data Data) {
 	g.grid.Upsert(object)
 }
 
+func (g *Game) setSeed(seed int64) {
+	g.grid.SetSeed(seed)
+}
+
+func (g *Game) setPhysics(physics PhysicsConfig) {
+	g.grid.config.SetPhysics(physics)
+}
+
+func (g *Game) setHeadScale(scale float64) {
+	g.grid.config.SetHeadScale(scale)
+}
+
+// Only the server builds one, after loading the level
+func (g *Game) buildNavGraph() {
+	g.navGraph = NewNavGraph(g.grid)
+}
+
+func (g *Game) getNavGraph() *NavGraph {
+	return g.navGraph
+}
+
+func (g *Game) findPath(from Vec2, to Vec2) ([]NavEdge, bool) {
+	if g.navGraph == nil {
+		return nil, false
+	}
+	return g.navGraph.FindPath(from, to)
+}
+
 func (g *Game) processKeyMsg(id IdType, keyMsg KeyMsg) {
 	if !g.grid.Has(Id(playerSpace, id)) {
 		return
@@ -61,11 +95,25 @@ func (g *Game) processKeyMsg(id IdType, keyMsg KeyMsg) {
 	player.UpdateKeys(keyMsg)
 }
 
-func (g *Game) updateState() {
-	now := time.Now()
-	g.grid.Update(now)
-	g.grid.Postprocess(now)
-	g.seqNum++
+func (g *Game) setLatency(id IdType, latency time.Duration) {
+	if !g.grid.Has(Id(playerSpace, id)) {
+		return
+	}
+	player := g.grid.Get(Id(playerSpace, id)).(*Player)
+	player.SetLatency(latency)
+}
+
+// Replay the player's input for tick, which the predictor then continues from
+func (g *Game) stepPlayer(id IdType, tick SeqNumType) {
+	g.grid.StepObject(Id(playerSpace, id), tick)
+	g.seqNum = tick
+}
+
+// Advance the simulation by one fixed step
+func (g *Game) updateState(tick SeqNumType, timestep time.Duration) {
+	g.grid.Update(tick, timestep)
+	g.grid.Postprocess()
+	g.seqNum = tick
 }
 
 func (g* Game) createPlayerInitMsg(id IdType) PlayerInitMsg {
@@ -86,6 +134,8 @@ func (g *Game) createLevelInitMsg() LevelInitMsg {
 	return LevelInitMsg{
 		T: levelInitType,
 		L: g.level,
+		P: g.grid.GetConfig().Physics(),
+		H: g.grid.GetConfig().HeadScale(),
 	}
 }
 
@@ -111,6 +161,7 @@ func (g *Game) createGameInitMsg() GameStateMsg {
 		T: objectDataType,
 		S: g.seqNum,
 		Os: g.grid.GetObjectInitData(),
+		Ms: g.grid.GetConfig().Mutators(),
 	}
 }
 
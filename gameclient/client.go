@@ -0,0 +1,476 @@
+// Package gameclient is a headless client that speaks the same protocol as the browser client.
+package gameclient
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v3"
+	"github.com/vmihailenco/msgpack/v5"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Ordered and reliable channel the server opens alongside the unreliable one, see client.go in the server
+const EventChannelLabel string = "events"
+
+type Handler func(b []byte)
+
+type Options struct {
+	Host string
+	Room string
+	Name string
+	Secure bool
+	WebRTC bool
+
+	// Watch a captured match instead of joining Room
+	Replay string
+}
+
+type Client struct {
+	options Options
+
+	ws *websocket.Conn
+	wrtc *webrtc.PeerConnection
+	dc *webrtc.DataChannel
+	dcOpen bool
+	edc *webrtc.DataChannel
+	edcOpen bool
+	candidates []webrtc.ICECandidateInit
+	mu sync.Mutex
+
+	id IdType
+	hasId bool
+	ready chan struct{}
+	closed chan struct{}
+
+	handlers map[MessageType][]Handler
+	stats *Stats
+
+	keySeqNum SeqNumType
+	stateSeqNum SeqNumType
+	stateChunks uint32
+	pingSeqNum SeqNumType
+	pings map[SeqNumType]time.Time
+	latency time.Duration
+}
+
+func Dial(options Options) (*Client, error) {
+	scheme := "ws"
+	if options.Secure {
+		scheme = "wss"
+	}
+	path := "/newclient/room=" + options.Room
+	if len(options.Replay) > 0 {
+		path = "/replay/file=" + options.Replay
+	}
+	endpoint := url.URL {
+		Scheme: scheme,
+		Host: options.Host,
+		Path: path + "&name=" + options.Name,
+	}
+
+	ws, _, err := websocket.DefaultDialer.Dial(endpoint.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client {
+		options: options,
+		ws: ws,
+		candidates: make([]webrtc.ICECandidateInit, 0),
+
+		ready: make(chan struct{}),
+		closed: make(chan struct{}),
+
+		handlers: make(map[MessageType][]Handler),
+		stats: NewStats(),
+
+		pings: make(map[SeqNumType]time.Time),
+		latency: 0,
+	}
+
+	c.AddHandler(InitType, c.handleInit)
+	c.AddHandler(PlayerInitType, c.handlePlayerInit)
+	c.AddHandler(AnswerType, c.handleAnswer)
+	c.AddHandler(CandidateType, c.handleCandidate)
+	c.AddHandler(PingType, c.handlePing)
+
+	go c.run()
+	return c, nil
+}
+
+// Handlers are called from the websocket and data channel goroutines
+func (c *Client) AddHandler(msgType MessageType, handler Handler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers[msgType] = append(c.handlers[msgType], handler)
+}
+
+func (c *Client) Id() IdType { return c.id }
+func (c *Client) Stats() *Stats { return c.stats }
+
+// Closed once the server sends the player init message
+func (c *Client) Ready() <-chan struct{} { return c.ready }
+func (c *Client) Closed() <-chan struct{} { return c.closed }
+
+func (c *Client) DataChannelOpen() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dcOpen
+}
+
+func (c *Client) Close() {
+	c.ws.Close()
+	if c.wrtc != nil {
+		c.wrtc.Close()
+	}
+}
+
+// Sends over the reliable event channel when available, otherwise over the websocket
+func (c *Client) Send(msg interface{}) error {
+	c.mu.Lock()
+	edcOpen := c.edcOpen
+	c.mu.Unlock()
+	if !edcOpen {
+		return c.sendWS(msg)
+	}
+
+	b, err := msgpack.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.edc.Send(b)
+}
+
+// WebRTC signaling always uses the websocket
+func (c *Client) sendWS(msg interface{}) error {
+	b, err := msgpack.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ws.WriteMessage(websocket.BinaryMessage, b)
+}
+
+// Sends over the data channel when available, otherwise over the websocket
+func (c *Client) SendUDP(msg interface{}) error {
+	if !c.DataChannelOpen() {
+		return c.Send(msg)
+	}
+
+	b, err := msgpack.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.dc.Send(b)
+}
+
+func (c *Client) SendKeys(keys []Key, mouse Vec2, dir Vec2) error {
+	c.mu.Lock()
+	c.keySeqNum++
+	seqNum := c.keySeqNum
+	ack := c.stateSeqNum
+	ackChunks := c.stateChunks
+	c.mu.Unlock()
+
+	msg := OutgoingMsg {
+		T: KeyType,
+		Key: &KeyMsg {
+			S: seqNum,
+			A: ack,
+			AC: ackChunks,
+			K: keys,
+			M: mouse,
+			D: dir,
+		},
+	}
+	return c.SendUDP(&msg)
+}
+
+func (c *Client) SendChat(message string) error {
+	msg := OutgoingMsg {
+		T: ChatType,
+		Chat: &ChatMsg {
+			M: message,
+		},
+	}
+	return c.Send(&msg)
+}
+
+func (c *Client) Ping() error {
+	c.mu.Lock()
+	c.pingSeqNum++
+	seqNum := c.pingSeqNum
+	c.pings[seqNum] = time.Now()
+	c.mu.Unlock()
+
+	msg := OutgoingMsg {
+		T: PingType,
+		Ping: &PingMsg {
+			S: seqNum,
+		},
+	}
+	return c.Send(&msg)
+}
+
+func (c *Client) run() {
+	defer close(c.closed)
+
+	for {
+		_, b, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.handlePayload(b)
+	}
+}
+
+func (c *Client) handlePayload(b []byte) {
+	msg := TypeMsg{}
+	if err := msgpack.Unmarshal(b, &msg); err != nil {
+		log.Printf("error unpacking: %v", err)
+		return
+	}
+	c.stats.recordMessage(msg.T, msg.S, len(b))
+
+	c.mu.Lock()
+	// Acked with keys so the server can send deltas against it
+	if msg.T == ObjectDataType && msg.S >= c.stateSeqNum {
+		if msg.S > c.stateSeqNum {
+			c.stateSeqNum = msg.S
+			c.stateChunks = 0
+		}
+		c.stateChunks |= 1 << msg.C
+	}
+	handlers := c.handlers[msg.T]
+	c.mu.Unlock()
+
+	for _, handler := range(handlers) {
+		handler(b)
+	}
+}
+
+func (c *Client) handleInit(b []byte) {
+	msg := ClientMsg{}
+	if err := msgpack.Unmarshal(b, &msg); err != nil {
+		log.Printf("error unpacking init: %v", err)
+		return
+	}
+	c.id = msg.Client.Id
+	c.hasId = true
+
+	// Uses the server's ICE servers, so it has to wait for this message
+	if c.options.WebRTC {
+		if err := c.initWebRTC(msg.WebRTC); err != nil {
+			log.Printf("error starting WebRTC, using websocket only: %v", err)
+		}
+	}
+}
+
+func (c *Client) handlePlayerInit(b []byte) {
+	select {
+	case <-c.ready:
+	default:
+		close(c.ready)
+	}
+}
+
+func (c *Client) handlePing(b []byte) {
+	msg := PingMsg{}
+	if err := msgpack.Unmarshal(b, &msg); err != nil {
+		return
+	}
+	if msg.E {
+		c.SendUDP(&OutgoingMsg {
+			T: PingType,
+			Ping: &PingMsg {
+				S: msg.S,
+				E: true,
+			},
+		})
+		return
+	}
+
+	c.mu.Lock()
+	sent, ok := c.pings[msg.S]
+	delete(c.pings, msg.S)
+	if ok {
+		c.latency = time.Now().Sub(sent)
+	}
+	latency := c.latency
+	c.mu.Unlock()
+
+	if ok {
+		c.stats.recordLatency(latency)
+	}
+}
+
+func (c *Client) initWebRTC(msg *WebRTCMsg) error {
+	var err error
+	config := webrtc.Configuration{}
+	if msg != nil {
+		for _, server := range(msg.Servers) {
+			config.ICEServers = append(config.ICEServers, webrtc.ICEServer {
+				URLs: server.URLs,
+				Username: server.Username,
+				Credential: server.Credential,
+			})
+		}
+		if msg.Relay {
+			config.ICETransportPolicy = webrtc.ICETransportPolicyRelay
+		}
+	}
+
+	c.wrtc, err = webrtc.NewPeerConnection(config)
+	if err != nil {
+		return err
+	}
+
+	// Needed so the offer includes a data section, the server opens its own channel
+	ordered := false
+	maxRetransmits := uint16(0)
+	_, err = c.wrtc.CreateDataChannel("data", &webrtc.DataChannelInit {
+		Ordered: &ordered,
+		MaxRetransmits: &maxRetransmits,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.wrtc.OnDataChannel(func(dc *webrtc.DataChannel) {
+		dc.OnOpen(func() {
+			c.mu.Lock()
+			if dc.Label() == EventChannelLabel {
+				c.edc = dc
+				c.edcOpen = true
+			} else {
+				c.dc = dc
+				c.dcOpen = true
+			}
+			c.mu.Unlock()
+		})
+		dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+			c.handlePayload(msg.Data)
+		})
+	})
+
+	c.wrtc.OnICECandidate(func(ice *webrtc.ICECandidate) {
+		if ice == nil {
+			return
+		}
+
+		candidate := ice.ToJSON()
+		sdpMid := ""
+		if candidate.SDPMid != nil {
+			sdpMid = *candidate.SDPMid
+		}
+		sdpMLineIndex := int8(0)
+		if candidate.SDPMLineIndex != nil {
+			sdpMLineIndex = int8(*candidate.SDPMLineIndex)
+		}
+
+		// Same format as the browser client
+		c.sendWS(&OutgoingMsg {
+			T: CandidateType,
+			JSON: map[string]interface{} {
+				"candidate": candidate.Candidate,
+				"sdpMid": sdpMid,
+				"sdpMLineIndex": sdpMLineIndex,
+			},
+		})
+	})
+
+	offer, err := c.wrtc.CreateOffer(nil)
+	if err != nil {
+		return err
+	}
+	err = c.wrtc.SetLocalDescription(offer)
+	if err != nil {
+		return err
+	}
+
+	return c.sendWS(&OutgoingMsg {
+		T: OfferType,
+		JSON: map[string]interface{} {
+			"type": "offer",
+			"sdp": offer.SDP,
+		},
+	})
+}
+
+func (c *Client) handleAnswer(b []byte) {
+	msg := struct {
+		T MessageType
+		JSON webrtc.SessionDescription
+	}{}
+	if err := msgpack.Unmarshal(b, &msg); err != nil {
+		log.Printf("error unpacking answer: %v", err)
+		return
+	}
+
+	msg.JSON.Type = webrtc.SDPTypeAnswer
+	if err := c.wrtc.SetRemoteDescription(msg.JSON); err != nil {
+		log.Printf("error setting remote description: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	candidates := c.candidates
+	c.candidates = make([]webrtc.ICECandidateInit, 0)
+	c.mu.Unlock()
+
+	for _, candidate := range(candidates) {
+		c.wrtc.AddICECandidate(candidate)
+	}
+}
+
+func (c *Client) handleCandidate(b []byte) {
+	if c.wrtc == nil {
+		return
+	}
+
+	msg := struct {
+		T MessageType
+		JSON webrtc.ICECandidateInit
+	}{}
+	if err := msgpack.Unmarshal(b, &msg); err != nil {
+		log.Printf("error unpacking candidate: %v", err)
+		return
+	}
+
+	if c.wrtc.RemoteDescription() == nil {
+		c.mu.Lock()
+		c.candidates = append(c.candidates, msg.JSON)
+		c.mu.Unlock()
+		return
+	}
+	c.wrtc.AddICECandidate(msg.JSON)
+}
+
+func DecodeGameState(b []byte) (GameStateMsg, error) {
+	msg := GameStateMsg{}
+	// Nested maps in props can have integer keys, so decode them untyped
+	decoder := msgpack.NewDecoder(bytes.NewReader(b))
+	decoder.SetMapDecoder(func(d *msgpack.Decoder) (interface{}, error) {
+		return d.DecodeUntypedMap()
+	})
+	err := decoder.Decode(&msg)
+	return msg, err
+}
+
+func (c *Client) WaitReady(timeout time.Duration) error {
+	select {
+	case <-c.ready:
+		return nil
+	case <-c.closed:
+		return errors.New("Connection closed before initialization")
+	case <-time.After(timeout):
+		return fmt.Errorf("Timed out after %v waiting for initialization", timeout)
+	}
+}
\ No newline at end of file
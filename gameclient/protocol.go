@@ -0,0 +1,145 @@
+package gameclient
+
+// Mirrors types.go in the server. Any changes there need to be reflected here.
+
+type MessageType uint8
+type SeqNumType uint32
+const (
+	UnknownType MessageType = iota
+
+	PingType
+	CandidateType
+	OfferType
+	AnswerType
+	VoiceCandidateType
+
+	VoiceOfferType
+	VoiceAnswerType
+	InitType
+	JoinType
+	LeftType
+
+	InitVoiceType
+	JoinVoiceType
+	LeftVoiceType
+	ChatType
+	KeyType
+
+	ObjectDataType
+	ObjectUpdateType
+	PlayerInitType
+	LevelInitType
+	KillcamType
+)
+
+type IdType uint16
+type SpaceType uint8
+type Prop uint8
+type LevelIdType uint8
+
+type Key uint16
+const (
+	UnknownKey Key = iota
+
+	UpKey
+	DownKey
+	LeftKey
+	RightKey
+
+	JumpKey
+	InteractKey
+
+	MouseClick
+	AltMouseClick
+)
+
+type Vec2 struct {
+	X float64
+	Y float64
+}
+
+type PropMap map[Prop]interface{}
+type ObjectPropMap map[SpaceType]map[IdType]PropMap
+
+type TypeMsg struct {
+	T MessageType
+	S SeqNumType
+	C uint8 `msgpack:",omitempty"`
+}
+
+// Wrapper for all messages sent to the server, see Msg in room.go
+type OutgoingMsg struct {
+	T MessageType
+	Ping *PingMsg `msgpack:",omitempty"`
+	JSON interface{} `msgpack:",omitempty"`
+	Chat *ChatMsg `msgpack:",omitempty"`
+	Key *KeyMsg `msgpack:",omitempty"`
+}
+
+type PingMsg struct {
+	T MessageType
+	S SeqNumType
+
+	// Set on pings from the server, which are echoed back so it can measure round trip time
+	E bool `msgpack:",omitempty"`
+}
+
+type ClientData struct {
+	Id IdType
+	Name string
+}
+
+type ClientMsg struct {
+	T MessageType
+	Client ClientData
+	Clients map[IdType]ClientData
+
+	// Only set in the init message
+	WebRTC *WebRTCMsg `msgpack:",omitempty"`
+}
+
+type WebRTCMsg struct {
+	Servers []ICEServerMsg
+	Relay bool `msgpack:",omitempty"`
+}
+
+type ICEServerMsg struct {
+	URLs []string
+	Username string `msgpack:",omitempty"`
+	Credential string `msgpack:",omitempty"`
+}
+
+type ChatMsg struct {
+	T MessageType
+	Id IdType
+	M string
+}
+
+type GameStateMsg struct {
+	T MessageType
+	S SeqNumType
+	Os ObjectPropMap
+	Ms []string
+}
+
+type PlayerInitMsg struct {
+	T MessageType
+	Id IdType
+	Ps map[IdType]PropMap
+}
+
+type LevelInitMsg struct {
+	T MessageType
+	L LevelIdType
+	P map[string]float64
+}
+
+type KeyMsg struct {
+	T MessageType
+	S SeqNumType
+	A SeqNumType `msgpack:",omitempty"`
+	AC uint32 `msgpack:",omitempty"`
+	K []Key
+	M Vec2
+	D Vec2
+}
\ No newline at end of file
@@ -0,0 +1,98 @@
+package gameclient
+
+import (
+	"sync"
+	"time"
+)
+
+type Stats struct {
+	mu sync.Mutex
+
+	start time.Time
+	// State for a tick can be split over several messages
+	stateTicks int
+	lastStateSeqNum SeqNumType
+	messages int
+	bytes int
+	maxBytes int
+	latency time.Duration
+	latencySamples int
+}
+
+type StatsSnapshot struct {
+	Duration time.Duration
+	StateTicks int
+	Messages int
+	Bytes int
+	MaxBytes int
+	Latency time.Duration
+}
+
+func NewStats() *Stats {
+	return &Stats {
+		start: time.Now(),
+	}
+}
+
+func (s *Stats) recordMessage(msgType MessageType, seqNum SeqNumType, size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages++
+	s.bytes += size
+	if size > s.maxBytes {
+		s.maxBytes = size
+	}
+	if msgType == ObjectDataType && seqNum > s.lastStateSeqNum {
+		s.stateTicks++
+		s.lastStateSeqNum = seqNum
+	}
+}
+
+func (s *Stats) recordLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latency += latency
+	s.latencySamples++
+}
+
+// Returns stats since the last flush and resets them
+func (s *Stats) Flush() StatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := StatsSnapshot {
+		Duration: time.Now().Sub(s.start),
+		StateTicks: s.stateTicks,
+		Messages: s.messages,
+		Bytes: s.bytes,
+		MaxBytes: s.maxBytes,
+	}
+	if s.latencySamples > 0 {
+		snapshot.Latency = s.latency / time.Duration(s.latencySamples)
+	}
+
+	s.start = time.Now()
+	s.stateTicks = 0
+	s.messages = 0
+	s.bytes = 0
+	s.maxBytes = 0
+	s.latency = 0
+	s.latencySamples = 0
+	return snapshot
+}
+
+func (ss StatsSnapshot) TickRate() float64 {
+	if ss.Duration <= 0 {
+		return 0
+	}
+	return float64(ss.StateTicks) / ss.Duration.Seconds()
+}
+
+func (ss StatsSnapshot) AvgBytes() float64 {
+	if ss.Messages == 0 {
+		return 0
+	}
+	return float64(ss.Bytes) / float64(ss.Messages)
+}
\ No newline at end of file
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"math/rand"
+	"sort"
 	"time"
 )
 
@@ -22,8 +24,18 @@ type Grid struct {
 	unitLength int
 	unitHeight int
 
+	config GameConfig
 	gameState GameState
 
+	clock Clock
+	tick SeqNumType
+	timestep time.Duration
+	seed int64
+	random *rand.Rand
+
+	// Player positions at the end of recent ticks for lag compensation
+	history map[SeqNumType]map[IdType]Vec2
+
 	lastId map[SpaceType]IdType
 	objects map[SpacedId]Object
 	spacedObjects map[SpaceType]map[IdType]Object
@@ -32,13 +44,22 @@ type Grid struct {
 	reverseGrid map[SpacedId][]GridCoord
 }
 
-func NewGrid(unitLength int, unitHeight int) *Grid {
+func NewGrid(unitLength int, unitHeight int, config GameConfig, clock Clock) *Grid {
 	return &Grid {
 		unitLength: unitLength,
 		unitHeight: unitHeight,
 
+		config: config,
 		gameState: NewGameState(),
 
+		clock: clock,
+		tick: 0,
+		timestep: frameTime,
+		seed: 0,
+		random: rand.New(rand.NewSource(0)),
+
+		history: make(map[SeqNumType]map[IdType]Vec2),
+
 		lastId: make(map[SpaceType]IdType, 0),
 		objects: make(map[SpacedId]Object, 0),
 		spacedObjects: make(map[SpaceType]map[IdType]Object, 0),
@@ -55,10 +76,48 @@ func (g *Grid) GetUnitHeight() int {
 	return g.unitHeight
 }
 
+func (g *Grid) GetConfig() GameConfig {
+	return g.config
+}
+
+func (g *Grid) Tick() SeqNumType {
+	return g.tick
+}
+
+// Simulation time of the current tick
+func (g *Grid) Now() time.Time {
+	return g.clock.Now()
+}
+
+// Length of the current tick in seconds
+func (g *Grid) Timestep() float64 {
+	return g.timestep.Seconds()
+}
+
+// Restarts the random sequence, so a replay that sets the recorded seed draws the same numbers
+func (g *Grid) SetSeed(seed int64) {
+	g.seed = seed
+	g.random = rand.New(rand.NewSource(seed))
+}
+
+func (g *Grid) Seed() int64 {
+	return g.seed
+}
+
+// Seeded once, so results are reproducible as long as the simulation draws in the same order
+func (g *Grid) Rand() *rand.Rand {
+	return g.random
+}
+
 func (g *Grid) New(init Init) Object {
 	switch init.GetSpace() {
 	case playerSpace:
-		return NewPlayer(init)
+		player := NewPlayer(init)
+		player.SetHeadScale(g.config.HeadScale())
+		player.SetArmorAbsorption(g.config.ArmorAbsorption())
+		player.SetShield(g.config.MaxShield(), g.config.ShieldDelay(), g.config.ShieldRate())
+		player.Respawn(g)
+		return player
 	case wallSpace:
 		return NewWall(init)
 	case weaponSpace:
@@ -155,25 +214,55 @@ func (g *Grid) deleteObject(sid SpacedId) {
 	delete(g.spacedObjects[sid.GetSpace()], sid.GetId())
 }
 
-func (g *Grid) Update(now time.Time) {
-	for _, object := range(g.GetAllObjects()) {
+func (g *Grid) Update(tick SeqNumType, timestep time.Duration) {
+	g.tick = tick
+	g.timestep = timestep
+	g.clock.Step(tick, timestep)
+	now := g.Now()
+
+	objects := g.getOrderedObjects()
+	for _, object := range(objects) {
 		object.Preprocess(g, now)
 	}
 
-	for _, object := range(g.GetAllObjects()) {
+	for _, object := range(objects) {
 		if object.GetSpace() == playerSpace {
 			continue
 		}
 		g.updateObject(object, now)
 	}
 
-	// Map iteration is random
-	for _, object := range(g.GetObjects(playerSpace)) {
+	for _, object := range(objects) {
+		if object.GetSpace() != playerSpace {
+			continue
+		}
 		g.updateObject(object, now)
 	}
 }
 
+// Map iteration is random, so update in id order to keep the simulation deterministic
+func (g *Grid) getOrderedObjects() []Object {
+	objects := make([]Object, 0, len(g.objects))
+	for _, object := range(g.objects) {
+		objects = append(objects, object)
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		a := objects[i].GetSpacedId()
+		b := objects[j].GetSpacedId()
+		if a.S != b.S {
+			return a.S < b.S
+		}
+		return a.Id < b.Id
+	})
+	return objects
+}
+
 func (g *Grid) updateObject(object Object, now time.Time) {
+	if !g.Has(object.GetSpacedId()) {
+		return
+	}
+
 	updated := object.UpdateState(g, now)
 	if updated {
 		// Update location in the grid
@@ -183,12 +272,66 @@ func (g *Grid) updateObject(object Object, now time.Time) {
 	}
 }
 
-func (g *Grid) Postprocess(now time.Time) {
-	for _, object := range(g.GetAllObjects()) {
-		object.Postprocess(g, now)
+// Advance one object to tick without stepping the rest of the simulation, used to replay inputs during prediction.
+// The clock moves with it so the next Update continues after the replayed tick instead of going back in time.
+func (g *Grid) StepObject(sid SpacedId, tick SeqNumType) {
+	object := g.Get(sid)
+	if object == nil {
+		return
+	}
+
+	g.clock.Step(tick, g.timestep)
+	now := g.Now()
+	object.Preprocess(g, now)
+	g.updateObject(object, now)
+	object.Postprocess(g, now)
+}
+
+func (g *Grid) Postprocess() {
+	for _, object := range(g.getOrderedObjects()) {
+		object.Postprocess(g, g.Now())
+	}
+	g.recordHistory()
+}
+
+func (g *Grid) recordHistory() {
+	if isWasm || g.config.MaxRewind() <= 0 {
+		return
+	}
+
+	positions := make(map[IdType]Vec2)
+	for id, player := range(g.GetObjects(playerSpace)) {
+		positions[id] = player.Pos()
+	}
+	g.history[g.tick] = positions
+
+	limit := SeqNumType(g.RewindTicks(g.config.MaxRewind()))
+	if g.tick > limit {
+		delete(g.history, g.tick - limit - 1)
 	}
 }
 
+// Number of ticks to rewind for the given latency, bounded by the config
+func (g *Grid) RewindTicks(latency time.Duration) int {
+	if latency <= 0 || g.timestep <= 0 {
+		return 0
+	}
+	if latency > g.config.MaxRewind() {
+		latency = g.config.MaxRewind()
+	}
+	return int(latency / g.timestep)
+}
+
+// Position of a player at the end of the given tick
+func (g *Grid) HistoricalPos(id IdType, tick SeqNumType) (Vec2, bool) {
+	positions, ok := g.history[tick]
+	if !ok {
+		return Vec2{}, false
+	}
+	pos, ok := positions[id]
+	return pos, ok
+}
+
 func (g *Grid) Delete(sid SpacedId) {
 	if isWasm {
 		g.deleteObject(sid)
@@ -268,6 +411,26 @@ func (g *Grid) GetObjectData() ObjectPropMap {
 	return objects
 }
 
+// Init data plus current data, i.e. everything a client needs to render each object
+func (g *Grid) GetObjectSnapshot() ObjectPropMap {
+	objects := make(ObjectPropMap)
+
+	for _, object := range(g.GetAllObjects()) {
+		// Init data has the full attribute maps, whereas data only has recent changes
+		data := object.GetData()
+		data.Merge(object.GetInitData())
+		if data.Size() == 0 {
+			continue
+		}
+
+		if _, ok := objects[object.GetSpace()]; !ok {
+			objects[object.GetSpace()] = make(SpacedPropMap)
+		}
+		objects[object.GetSpace()][object.GetId()] = data.Props()
+	}
+	return objects
+}
+
 func (g *Grid) GetObjectUpdates() ObjectPropMap {
 	objects := make(ObjectPropMap)
 
@@ -348,11 +511,23 @@ func (g *Grid) GetManyObjects(spaces ...SpaceType) map[SpaceType]map[IdType]Obje
 	return objects
 }
 
+// Objects in any grid cell overlapping the rectangle, so some may be just outside it
+func (g *Grid) GetObjectsInRect(pos Vec2, dim Vec2) map[SpacedId]Object {
+	objects := make(map[SpacedId]Object)
+
+	for _, coord := range(g.getRectCoords(pos, dim)) {
+		for sid, object := range(g.grid[coord]) {
+			objects[sid] = object
+		}
+	}
+	return objects
+}
+
 func (g *Grid) GetColliders(object Object) ObjectHeap {
 	heap := make(ObjectHeap, 0)
 
 	for _, other := range(g.getNearbyObjects(object)) {
-		results := object.OverlapProfile(other.GetProfile())
+		results := OverlapHit(object, other)
 		if results.hit {
 			item := &ObjectItem {
 				object: other,
@@ -364,6 +539,17 @@ func (g *Grid) GetColliders(object Object) ObjectHeap {
 	return heap
 }
 
+// Overlap with other, including any area of it that only counts for hits
+func OverlapHit(object Object, other Object) CollideResult {
+	result := object.OverlapProfile(other.GetProfile())
+	if player, ok := other.(*Player); ok && !result.hit {
+		if head := player.HitProfile(); head != nil {
+			result = object.OverlapProfile(head)
+		}
+	}
+	return result
+}
+
 func (g* Grid) getCoord(point Vec2) GridCoord {
 	cx := IntDown(point.X)
 	cy := IntDown(point.Y)
@@ -378,6 +564,19 @@ func (g* Grid) getCoords(object Object) []GridCoord {
 	pos := object.Pos()
 	dim := object.Dim()
 
+	// Cover the hit area too, so shots near it find the player
+	if player, ok := object.(*Player); ok {
+		if head := player.HitProfile(); head != nil {
+			min := NewVec2(Min(pos.X - dim.X / 2, head.Pos().X - head.Dim().X / 2), Min(pos.Y - dim.Y / 2, head.Pos().Y - head.Dim().Y / 2))
+			max := NewVec2(Max(pos.X + dim.X / 2, head.Pos().X + head.Dim().X / 2), Max(pos.Y + dim.Y / 2, head.Pos().Y + head.Dim().Y / 2))
+			pos = NewVec2((min.X + max.X) / 2, (min.Y + max.Y) / 2)
+			dim = NewVec2(max.X - min.X, max.Y - min.Y)
+		}
+	}
+	return g.getRectCoords(pos, dim)
+}
+
+func (g* Grid) getRectCoords(pos Vec2, dim Vec2) []GridCoord {
 	coords := make([]GridCoord, 0)
 
 	xmin := pos.X - dim.X / 2
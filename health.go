@@ -1,12 +1,16 @@
 package main
 
 import (
+	"math"
 	"time"
 )
 
 const (
 	maxDamageTicks int = 10
 	lastDamageTime time.Duration = 10 * time.Second
+
+	maxArmor int = 100
+	defaultArmorAbsorption float64 = 0.66
 )
 
 type DamageTick struct {
@@ -18,18 +22,41 @@ type DamageTick struct {
 type Health struct {
 	enabled bool
 	health int
+	maxHealth int
+	regen float64
 	ticks []DamageTick
+
+	armor int
+	armorAbsorption float64
+
+	shield float64
+	maxShield int
+	shieldDelay time.Duration
+	shieldRate float64
 }
 
 func NewHealth() Health {
 	return Health {
 		enabled: false,
 		health: 0,
+		maxHealth: defaultMaxHealth,
+		regen: 0,
+
+		armor: 0,
+		armorAbsorption: defaultArmorAbsorption,
+
+		shield: 0,
+		maxShield: 0,
+		shieldDelay: 0,
+		shieldRate: 0,
 	}
 }
 
 func (h *Health) Respawn() {
 	h.ticks = make([]DamageTick, 0)
+	h.regen = 0
+	h.armor = 0
+	h.shield = float64(h.maxShield)
 }
 
 func (h *Health) Die() {
@@ -45,6 +72,91 @@ func (h Health) GetHealth() int {
 	return h.health
 }
 
+func (h *Health) SetMaxHealth(maxHealth int) {
+	h.maxHealth = maxHealth
+	if h.health > maxHealth {
+		h.health = maxHealth
+	}
+}
+
+func (h Health) GetMaxHealth() int {
+	return h.maxHealth
+}
+
+func (h *Health) Heal(health int) {
+	if !h.enabled || h.Dead() || isWasm {
+		return
+	}
+
+	h.health += health
+	if h.health > h.maxHealth {
+		h.health = h.maxHealth
+	}
+}
+
+// Regenerate health at rate per second once delay has passed without damage.
+func (h *Health) Regenerate(now time.Time, ts float64, delay time.Duration, rate float64) {
+	if h.health >= h.maxHealth || h.Dead() {
+		h.regen = 0
+		return
+	}
+
+	if len(h.ticks) > 0 && now.Sub(h.ticks[len(h.ticks)-1].time) < delay {
+		h.regen = 0
+		return
+	}
+
+	h.regen += rate * ts
+	if h.regen >= 1 {
+		health := int(h.regen)
+		h.regen -= float64(health)
+		h.Heal(health)
+	}
+}
+
+func (h *Health) SetArmorAbsorption(absorption float64) {
+	h.armorAbsorption = Clamp(0, absorption, 1)
+}
+
+func (h *Health) AddArmor(armor int) {
+	h.armor += armor
+	if h.armor > maxArmor {
+		h.armor = maxArmor
+	}
+}
+
+func (h Health) GetArmor() int {
+	return h.armor
+}
+
+// Shield absorbs all damage until depleted and recharges at rate per second once delay has passed without damage.
+func (h *Health) SetShield(maxShield int, delay time.Duration, rate float64) {
+	h.maxShield = maxShield
+	h.shieldDelay = delay
+	h.shieldRate = rate
+	h.shield = Min(h.shield, float64(maxShield))
+}
+
+func (h Health) GetShield() int {
+	return int(math.Round(h.shield))
+}
+
+func (h Health) HasShield() bool {
+	return h.maxShield > 0
+}
+
+func (h *Health) RechargeShield(now time.Time, ts float64) {
+	if !h.HasShield() || h.shield >= float64(h.maxShield) {
+		return
+	}
+
+	if len(h.ticks) > 0 && now.Sub(h.ticks[len(h.ticks)-1].time) < h.shieldDelay {
+		return
+	}
+
+	h.shield = Min(h.shield + h.shieldRate * ts, float64(h.maxShield))
+}
+
 func (h Health) Dead() bool {
 	if isWasm || !h.enabled {
 		return false
@@ -52,43 +164,67 @@ func (h Health) Dead() bool {
 	return h.health <= 0
 }
 
-func (h Health) GetLastTicks(duration time.Duration) []DamageTick {
-	currentTime := time.Now()
+func (h Health) GetLastTicks(duration time.Duration, now time.Time) []DamageTick {
 	for i, tick := range(h.ticks) {
-		if currentTime.Sub(tick.time) <= duration {
+		if now.Sub(tick.time) <= duration {
 			return h.ticks[i:]
 		}
 	}
 	return make([]DamageTick, 0)
 }
 
-func (h Health) GetLastDamageId(duration time.Duration) SpacedId {
+func (h Health) GetLastDamageId(duration time.Duration, now time.Time) SpacedId {
 	if len(h.ticks) == 0 {
 		return InvalidId()
 	}
 
 	tick := h.ticks[len(h.ticks)-1]
 
-	if time.Now().Sub(tick.time) <= duration {
+	if now.Sub(tick.time) <= duration {
 		return tick.sid
 	}
 	return InvalidId()
 }
 
-func (h *Health) TakeDamage(sid SpacedId, damage int) {
+func (h *Health) TakeDamage(sid SpacedId, damage int, now time.Time) {
 	if !h.enabled || h.Dead() || isWasm {
 		return
 	}
-	h.SetHealth(h.health - damage)
 
 	tick := DamageTick {
 		sid: sid,
 		damage: damage,
-		time: time.Now(),
+		time: now,
 	}
 	h.ticks = append(h.ticks, tick)
 
 	if len(h.ticks) > maxDamageTicks {
 		h.ticks = h.ticks[1 : maxDamageTicks + 1]
 	}
+
+	damage = h.absorbDamage(damage)
+	h.SetHealth(h.health - damage)
+}
+
+// Returns the damage remaining after shield and armor.
+func (h *Health) absorbDamage(damage int) int {
+	if h.shield > 0 {
+		absorbed := Min(h.shield, float64(damage))
+		h.shield -= absorbed
+		damage -= int(math.Ceil(absorbed))
+	}
+
+	if h.armor > 0 && damage > 0 {
+		absorbed := int(math.Round(float64(damage) * h.armorAbsorption))
+		if absorbed > h.armor {
+			absorbed = h.armor
+		}
+		h.armor -= absorbed
+		damage -= absorbed
+	}
+
+	if damage < 0 {
+		return 0
+	}
+	return damage
 }
\ No newline at end of file
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	minInputBufferDepth int = 1
+	maxInputBufferDepth int = 8
+	initialInputBufferDepth int = 2
+
+	// Smoothing for the interarrival jitter estimate, as in RTP
+	inputJitterGain float64 = 1.0 / 16
+)
+
+type InputBufferStats struct {
+	Depth int
+	Queued int
+
+	Late int
+	Duplicate int
+	Dropped int
+	Underruns int
+}
+
+// Smooths out bursts and gaps in a player's inputs so exactly one is applied per tick
+type InputBuffer struct {
+	inputs []KeyMsg
+	lastSeqNum SeqNumType
+	started bool
+
+	depth int
+	jitter float64
+	lastArrivalTick SeqNumType
+	lastArrivalSeqNum SeqNumType
+
+	late int
+	duplicate int
+	dropped int
+	underruns int
+}
+
+func NewInputBuffer() *InputBuffer {
+	return &InputBuffer {
+		inputs: make([]KeyMsg, 0),
+		lastSeqNum: 0,
+		started: false,
+
+		depth: initialInputBufferDepth,
+		jitter: 0,
+		lastArrivalTick: 0,
+		lastArrivalSeqNum: 0,
+	}
+}
+
+// Tick is the server tick when the input arrived
+func (ib *InputBuffer) Push(keyMsg KeyMsg, tick SeqNumType) {
+	ib.updateJitter(keyMsg.S, tick)
+
+	if keyMsg.S <= ib.lastSeqNum {
+		ib.late++
+		return
+	}
+
+	index := sort.Search(len(ib.inputs), func(i int) bool {
+		return ib.inputs[i].S >= keyMsg.S
+	})
+	if index < len(ib.inputs) && ib.inputs[index].S == keyMsg.S {
+		ib.duplicate++
+		return
+	}
+
+	ib.inputs = append(ib.inputs, KeyMsg{})
+	copy(ib.inputs[index + 1:], ib.inputs[index:])
+	ib.inputs[index] = keyMsg
+}
+
+// Returns the input for this tick, or false if the player should keep their previous input
+func (ib *InputBuffer) Pop() (KeyMsg, bool) {
+	if !ib.started {
+		if len(ib.inputs) < ib.depth {
+			return KeyMsg{}, false
+		}
+		ib.started = true
+	}
+
+	if len(ib.inputs) == 0 {
+		ib.underruns++
+
+		// Refill before consuming again
+		ib.started = false
+		return KeyMsg{}, false
+	}
+
+	// The client is running ahead, so skip old inputs to keep the added delay bounded
+	if excess := len(ib.inputs) - 2 * ib.depth; excess > 0 {
+		ib.dropped += excess
+		ib.inputs = ib.inputs[excess:]
+	}
+
+	keyMsg := ib.inputs[0]
+	ib.inputs = ib.inputs[1:]
+	ib.lastSeqNum = keyMsg.S
+	return keyMsg, true
+}
+
+// Size the buffer from how much arrivals deviate from one input per tick. A client that is
+// consistently slow has little jitter, so it doesn't get a deeper buffer for nothing.
+func (ib *InputBuffer) updateJitter(seqNum SeqNumType, tick SeqNumType) {
+	if seqNum <= ib.lastArrivalSeqNum {
+		return
+	}
+
+	if ib.lastArrivalSeqNum > 0 {
+		deviation := float64(tick - ib.lastArrivalTick) - float64(seqNum - ib.lastArrivalSeqNum)
+		ib.jitter += (Abs(deviation) - ib.jitter) * inputJitterGain
+		ib.depth = int(Clamp(float64(minInputBufferDepth), 1 + math.Ceil(2 * ib.jitter), float64(maxInputBufferDepth)))
+	}
+	ib.lastArrivalTick = tick
+	ib.lastArrivalSeqNum = seqNum
+}
+
+// Returns stats since the last flush
+func (ib *InputBuffer) FlushStats() InputBufferStats {
+	stats := InputBufferStats {
+		Depth: ib.depth,
+		Queued: len(ib.inputs),
+
+		Late: ib.late,
+		Duplicate: ib.duplicate,
+		Dropped: ib.dropped,
+		Underruns: ib.underruns,
+	}
+
+	ib.late = 0
+	ib.duplicate = 0
+	ib.dropped = 0
+	ib.underruns = 0
+	return stats
+}
\ No newline at end of file
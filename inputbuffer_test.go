@@ -0,0 +1,137 @@
+package main
+
+import (
+	"testing"
+)
+
+func pushInputs(ib *InputBuffer, tick SeqNumType, seqNums ...SeqNumType) {
+	for _, seqNum := range(seqNums) {
+		ib.Push(KeyMsg { T: keyType, S: seqNum }, tick)
+	}
+}
+
+func expectPop(t *testing.T, ib *InputBuffer, seqNum SeqNumType) {
+	t.Helper()
+	keyMsg, ok := ib.Pop()
+	if !ok {
+		t.Fatalf("Expected input %d, got nothing", seqNum)
+	}
+	if keyMsg.S != seqNum {
+		t.Fatalf("Expected input %d, got %d", seqNum, keyMsg.S)
+	}
+}
+
+func expectNoPop(t *testing.T, ib *InputBuffer) {
+	t.Helper()
+	if keyMsg, ok := ib.Pop(); ok {
+		t.Fatalf("Expected no input, got %d", keyMsg.S)
+	}
+}
+
+func TestInputBufferReorders(t *testing.T) {
+	ib := NewInputBuffer()
+	pushInputs(ib, 1, 2, 1, 3)
+
+	expectPop(t, ib, 1)
+	expectPop(t, ib, 2)
+	expectPop(t, ib, 3)
+}
+
+func TestInputBufferDuplicates(t *testing.T) {
+	ib := NewInputBuffer()
+	pushInputs(ib, 1, 1, 2, 1, 2)
+
+	expectPop(t, ib, 1)
+	expectPop(t, ib, 2)
+	if stats := ib.FlushStats(); stats.Duplicate != 2 || stats.Queued != 0 {
+		t.Errorf("Expected 2 duplicates and nothing queued, got %+v", stats)
+	}
+}
+
+func TestInputBufferLate(t *testing.T) {
+	ib := NewInputBuffer()
+	pushInputs(ib, 1, 1, 2, 3)
+	expectPop(t, ib, 1)
+	expectPop(t, ib, 2)
+
+	// Already past these, so applying them would go back in time
+	pushInputs(ib, 2, 1, 2)
+	expectPop(t, ib, 3)
+	if stats := ib.FlushStats(); stats.Late != 2 {
+		t.Errorf("Expected 2 late inputs, got %+v", stats)
+	}
+}
+
+func TestInputBufferUnderrunRefill(t *testing.T) {
+	ib := NewInputBuffer()
+
+	// Waits for the initial depth before consuming
+	pushInputs(ib, 1, 1)
+	expectNoPop(t, ib)
+	pushInputs(ib, 2, 2)
+	expectPop(t, ib, 1)
+	expectPop(t, ib, 2)
+
+	expectNoPop(t, ib)
+	if stats := ib.FlushStats(); stats.Underruns != 1 {
+		t.Errorf("Expected 1 underrun, got %+v", stats)
+	}
+
+	// Refills to the depth again before consuming
+	pushInputs(ib, 5, 3)
+	expectNoPop(t, ib)
+	pushInputs(ib, 6, 4)
+	expectPop(t, ib, 3)
+	expectPop(t, ib, 4)
+	if stats := ib.FlushStats(); stats.Underruns != 0 {
+		t.Errorf("Expected no underruns while refilling, got %+v", stats)
+	}
+}
+
+func TestInputBufferDropsExcess(t *testing.T) {
+	ib := NewInputBuffer()
+	pushInputs(ib, 1, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+
+	// Keeps at most twice the depth queued
+	depth := SeqNumType(ib.FlushStats().Depth)
+	expectPop(t, ib, 10 - 2 * depth + 1)
+	if stats := ib.FlushStats(); SeqNumType(stats.Dropped) != 10 - 2 * depth {
+		t.Errorf("Expected %d dropped inputs, got %+v", 10 - 2 * depth, stats)
+	}
+}
+
+func TestInputBufferDepth(t *testing.T) {
+	ib := NewInputBuffer()
+	if depth := ib.FlushStats().Depth; depth != initialInputBufferDepth {
+		t.Fatalf("Expected initial depth %d, got %d", initialInputBufferDepth, depth)
+	}
+
+	// Bursts of four inputs every four ticks
+	seqNum := SeqNumType(0)
+	tick := SeqNumType(0)
+	for i := 0; i < 50; i++ {
+		tick += 4
+		for j := 0; j < 4; j++ {
+			seqNum++
+			pushInputs(ib, tick, seqNum)
+		}
+	}
+	bursty := ib.FlushStats().Depth
+	if bursty <= initialInputBufferDepth {
+		t.Fatalf("Depth should grow with jittery arrivals, got %d", bursty)
+	}
+	if bursty > maxInputBufferDepth {
+		t.Fatalf("Depth %d is over the max %d", bursty, maxInputBufferDepth)
+	}
+
+	// Steady arrivals, even if consistently delayed
+	tick += 10
+	for i := 0; i < 200; i++ {
+		tick++
+		seqNum++
+		pushInputs(ib, tick, seqNum)
+	}
+	if steady := ib.FlushStats().Depth; steady >= bursty || steady > initialInputBufferDepth {
+		t.Errorf("Depth should shrink back with steady arrivals, got %d after %d", steady, bursty)
+	}
+}
\ No newline at end of file
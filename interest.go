@@ -0,0 +1,79 @@
+package main
+
+const (
+	// Well beyond what the camera shows, so objects leave the area before they leave the screen
+	interestWidth float64 = 64
+	interestHeight float64 = 40
+)
+
+// Objects a client gets state for: everything near their player, plus their player, anything it owns and
+// the level geometry. Objects that leave the area are hidden on the client until they come back. Scores
+// and other updates are sent reliably to everyone, so they aren't filtered.
+type Interest struct {
+	player SpacedId
+	relevant map[SpacedId]bool
+	// Objects that stopped or started being relevant in the last update
+	left []SpacedId
+	entered []SpacedId
+
+	// No player to center on, e.g. before spawning, so everything is relevant
+	all bool
+}
+
+func NewInterest(id IdType) *Interest {
+	return &Interest {
+		player: Id(playerSpace, id),
+		relevant: make(map[SpacedId]bool),
+		left: make([]SpacedId, 0),
+		entered: make([]SpacedId, 0),
+		all: true,
+	}
+}
+
+func (i *Interest) Player() SpacedId {
+	return i.player
+}
+
+func (i *Interest) Update(grid *Grid) {
+	all := !grid.Has(i.player)
+	relevant := make(map[SpacedId]bool)
+	if !all {
+		for sid := range(grid.GetObjectsInRect(grid.Get(i.player).Pos(), NewVec2(interestWidth, interestHeight))) {
+			relevant[sid] = true
+		}
+	}
+
+	i.left = make([]SpacedId, 0)
+	i.entered = make([]SpacedId, 0)
+	for sid, object := range(grid.GetAllObjects()) {
+		// Walls are built by the client when the level loads and can't be rebuilt once hidden, and prediction collides with them
+		if sid == i.player || object.GetOwner() == i.player || sid.GetSpace() == wallSpace {
+			relevant[sid] = true
+		}
+
+		was := i.Relevant(sid)
+		is := all || relevant[sid]
+		if was && !is {
+			i.left = append(i.left, sid)
+		} else if !was && is {
+			i.entered = append(i.entered, sid)
+		}
+	}
+
+	i.relevant = relevant
+	i.all = all
+}
+
+// Objects that left the area in the last update. Deleted objects aren't included since they're removed reliably.
+func (i *Interest) Left() []SpacedId {
+	return i.left
+}
+
+// Objects that came back into the area in the last update
+func (i *Interest) Entered() []SpacedId {
+	return i.entered
+}
+
+func (i *Interest) Relevant(sid SpacedId) bool {
+	return i.all || i.relevant[sid]
+}
\ No newline at end of file
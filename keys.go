@@ -5,6 +5,9 @@ type Keys struct {
 	keys map[KeyType]bool
 	lastKeys map[KeyType]bool
 	lastKeyChange map[KeyType]SeqNumType
+
+	// Latest input sequence number that has been applied
+	lastSeqNum SeqNumType
 }
 
 func NewKeys() Keys {
@@ -13,6 +16,8 @@ func NewKeys() Keys {
 		keys: make(map[KeyType]bool),
 		lastKeys: make(map[KeyType]bool),
 		lastKeyChange: make(map[KeyType]SeqNumType),
+
+		lastSeqNum: 0,
 	}
 }
 
@@ -47,8 +52,15 @@ func (k Keys) KeyPressed(key KeyType) bool {
 	return pressed
 }
 
+func (k Keys) LastSeqNum() SeqNumType {
+	return k.lastSeqNum
+}
+
 func (k *Keys) UpdateKeys(keyMsg KeyMsg) {
 	seqNum := keyMsg.S
+	if seqNum > k.lastSeqNum {
+		k.lastSeqNum = seqNum
+	}
 
 	// Press keys and convert keyMsg.K to a set
 	keys := make(map[KeyType]bool)
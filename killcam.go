@@ -0,0 +1,158 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	// Window of recent ticks kept for killcams
+	killcamDuration time.Duration = 2 * time.Second
+
+	// Playback is squeezed into the death timer, so not every tick needs to be sent
+	killcamFrameInterval int = 2
+)
+
+var killcamSpaces = []SpaceType {playerSpace, bombSpace, pelletSpace, boltSpace, rocketSpace, starSpace}
+
+type KillcamObject struct {
+	P Vec2
+	D Vec2
+}
+
+type KillcamFrame struct {
+	S SeqNumType
+	Os map[SpaceType]map[IdType]KillcamObject
+}
+
+// Sent to a player when they die. Frames span the window before the kill and should be played back over D milliseconds.
+type KillcamMsg struct {
+	T MessageType
+	K IdType
+	V IdType
+	D int
+	Fs []KillcamFrame
+}
+
+type killcamEntry struct {
+	object KillcamObject
+	owner SpacedId
+}
+
+type killcamSnapshot struct {
+	tick SeqNumType
+	objects map[SpacedId]killcamEntry
+}
+
+type Kill struct {
+	killer IdType
+	victim IdType
+}
+
+// Rolling buffer of player and projectile positions
+type Killcam struct {
+	snapshots []killcamSnapshot
+	next int
+	size int
+
+	dead map[IdType]bool
+}
+
+func NewKillcam() *Killcam {
+	return &Killcam {
+		snapshots: make([]killcamSnapshot, int(killcamDuration / frameTime)),
+		next: 0,
+		size: 0,
+
+		dead: make(map[IdType]bool),
+	}
+}
+
+// Snapshot the current tick and return any players killed by another player during it
+func (k *Killcam) Record(grid *Grid) []Kill {
+	snapshot := killcamSnapshot {
+		tick: grid.Tick(),
+		objects: make(map[SpacedId]killcamEntry),
+	}
+
+	for _, space := range(killcamSpaces) {
+		for _, object := range(grid.GetObjects(space)) {
+			snapshot.objects[object.GetSpacedId()] = killcamEntry {
+				object: KillcamObject {
+					P: object.Pos(),
+					D: object.Dir(),
+				},
+				owner: object.GetOwner(),
+			}
+		}
+	}
+
+	k.snapshots[k.next] = snapshot
+	k.next = (k.next + 1) % len(k.snapshots)
+	if k.size < len(k.snapshots) {
+		k.size++
+	}
+
+	kills := make([]Kill, 0)
+	for id, object := range(grid.GetObjects(playerSpace)) {
+		player := object.(*Player)
+		if !player.Dead() {
+			delete(k.dead, id)
+			continue
+		}
+		if k.dead[id] {
+			continue
+		}
+
+		k.dead[id] = true
+		killer := player.GetLastDamageId(lastDamageTime, grid.Now())
+		if killer.Invalid() || killer.GetSpace() != playerSpace || killer.GetId() == id {
+			continue
+		}
+		kills = append(kills, Kill {
+			killer: killer.GetId(),
+			victim: id,
+		})
+	}
+	return kills
+}
+
+// Build the replay of a kill from the killer's perspective
+func (k *Killcam) CreateMsg(kill Kill) KillcamMsg {
+	killer := Id(playerSpace, kill.killer)
+	victim := Id(playerSpace, kill.victim)
+
+	frames := make([]KillcamFrame, 0, k.size / killcamFrameInterval + 1)
+	for i := (k.size - 1) % killcamFrameInterval; i < k.size; i += killcamFrameInterval {
+		snapshot := k.snapshots[(k.next - k.size + i + len(k.snapshots)) % len(k.snapshots)]
+		frame := KillcamFrame {
+			S: snapshot.tick,
+			Os: make(map[SpaceType]map[IdType]KillcamObject),
+		}
+
+		for sid, entry := range(snapshot.objects) {
+			if sid != killer && sid != victim && entry.owner != killer {
+				continue
+			}
+			if _, ok := frame.Os[sid.GetSpace()]; !ok {
+				frame.Os[sid.GetSpace()] = make(map[IdType]KillcamObject)
+			}
+			frame.Os[sid.GetSpace()][sid.GetId()] = entry.object
+		}
+		frames = append(frames, frame)
+	}
+
+	return KillcamMsg {
+		T: killcamType,
+		K: kill.killer,
+		V: kill.victim,
+		D: int(deathDuration / time.Millisecond),
+		Fs: frames,
+	}
+}
+
+// Forget deaths so stale state doesn't carry over, e.g. after a level change
+func (k *Killcam) Reset() {
+	k.next = 0
+	k.size = 0
+	k.dead = make(map[IdType]bool)
+}
\ No newline at end of file
@@ -1,9 +1,14 @@
 package main
 
+import (
+	"time"
+)
+
 func (g *Game) loadLevel(index LevelIdType) {
 	switch index {
 	case testLevel:
 		g.level = index
+		g.navGraph = nil
 		g.loadTestLevel()
 	default:
 		Debug("Unknown map: %d", index)
@@ -42,6 +47,10 @@ func (g *Game) loadTestLevel() {
 	g.grid.GetLast(wallSpace).(*Wall).SetVel(NewVec2(0, 2))
 	g.grid.GetLast(wallSpace).(*Wall).SetYBounds(2, 5)
 	g.add(g.createInit(wallSpace, NewVec2(11, 15), NewVec2(1, 1)))
+	g.add(g.createInitB(pickupSpace, NewVec2(11, 15.5), NewVec2(0.8, 0.8)))
+	g.grid.GetLast(pickupSpace).(*Pickup).SetPickupType(healthPickup)
+	g.grid.GetLast(pickupSpace).(*Pickup).SetAmount(50)
+	g.grid.GetLast(pickupSpace).(*Pickup).SetRespawnTime(15 * time.Second)
 
 	g.add(g.createInitBL(wallSpace, NewVec2(14, -6), NewVec2(16, 10)))
 	g.add(g.createInitBL(wallSpace, NewVec2(14, 9), NewVec2(16, 0.5)))
@@ -66,6 +75,10 @@ func (g *Game) loadTestLevel() {
 	g.grid.GetLast(wallSpace).(*Wall).AddAttribute(platformAttribute)
 	g.add(g.createInit(wallSpace, NewVec2(22, 13.5), NewVec2(3, 0.2)))
 	g.grid.GetLast(wallSpace).(*Wall).AddAttribute(platformAttribute)
+	g.add(g.createInitB(pickupSpace, NewVec2(22, 13.6), NewVec2(0.8, 0.8)))
+	g.grid.GetLast(pickupSpace).(*Pickup).SetPowerUpType(speedPowerUp)
+	g.grid.GetLast(pickupSpace).(*Pickup).SetAmount(10)
+	g.grid.GetLast(pickupSpace).(*Pickup).SetRespawnTime(30 * time.Second)
 	g.add(g.createInit(wallSpace, NewVec2(26, 11.5), NewVec2(3, 0.2)))
 	g.grid.GetLast(wallSpace).(*Wall).AddAttribute(platformAttribute)
 
@@ -74,6 +87,10 @@ func (g *Game) loadTestLevel() {
 	g.grid.GetLast(wallSpace).(*Wall).SetVel(NewVec2(0, 2))
 	g.grid.GetLast(wallSpace).(*Wall).SetYBounds(2, 5)
 	g.add(g.createInit(wallSpace, NewVec2(33, 15), NewVec2(1, 1)))
+	g.add(g.createInitB(pickupSpace, NewVec2(33, 15.5), NewVec2(0.8, 0.8)))
+	g.grid.GetLast(pickupSpace).(*Pickup).SetPickupType(armorPickup)
+	g.grid.GetLast(pickupSpace).(*Pickup).SetAmount(50)
+	g.grid.GetLast(pickupSpace).(*Pickup).SetRespawnTime(20 * time.Second)
 
 	g.add(g.createInitBL(wallSpace, NewVec2(36, -6), NewVec2(8, 12)))
 	g.add(g.createInit(wallSpace, NewVec2(40, 8), NewVec2(3, 0.2)))
@@ -5,18 +5,51 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
 
 const (
 	newClient string = "/newclient/"
+	maxBots int = 16
+	maxRewindMillis int = 500
 )
 
 var upgrader = websocket.Upgrader{}
 
+// Settings from the environment, read once at startup and shared by every room and replay
+type ServerConfig struct {
+	recordDir string
+	captureDir string
+	stateChunkSize int
+	stateBudget int
+	webRTC WebRTCConfig
+}
+
+var serverConfig ServerConfig
+
+func NewServerConfigFromEnv() ServerConfig {
+	return ServerConfig {
+		recordDir: os.Getenv("RECORD_DIR"),
+		captureDir: os.Getenv("CAPTURE_DIR"),
+		stateChunkSize: StateChunkSizeFromEnv(),
+		stateBudget: StateBudgetFromEnv(),
+		webRTC: NewWebRTCConfigFromEnv(),
+	}
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err := runReplay(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	serverConfig = NewServerConfigFromEnv()
 	http.HandleFunc(newClient, newClientHandler)
+	http.HandleFunc(replayEndpoint, replayHandler)
 	serveFiles("/")
 
 	port := os.Getenv("PORT")
@@ -33,7 +66,7 @@ func main() {
 
 func newClientHandler(w http.ResponseWriter, r *http.Request) {
 	stuff := strings.Split(r.URL.Path[len(newClient):], "&")
-	if len(stuff) != 2 {
+	if len(stuff) < 2 {
 		log.Printf("Malformed request: %s", r.URL.Path)
 		return
 	}
@@ -41,14 +74,53 @@ func newClientHandler(w http.ResponseWriter, r *http.Request) {
 	const (
 		roomPrefix string = "room="
 		namePrefix string = "name="
+		regenPrefix string = "regen="
+		rewindPrefix string = "rewind="
+		mutatorsPrefix string = "mutators="
+		botsPrefix string = "bots="
+		codecPrefix string = "codec="
 	)
 	var room string
 	var name string
+	var compact bool
+	config := NewRoomConfig()
+	config.recordDir = serverConfig.recordDir
+	config.captureDir = serverConfig.captureDir
+	config.stateChunkSize = serverConfig.stateChunkSize
+	config.stateBudget = serverConfig.stateBudget
+	config.webRTC = serverConfig.webRTC
 	for _, param := range stuff {
 		if strings.HasPrefix(param, roomPrefix) {
 			room = strings.TrimPrefix(param, roomPrefix)
 		} else if strings.HasPrefix(param, namePrefix) {
 			name = strings.TrimPrefix(param, namePrefix)
+		} else if strings.HasPrefix(param, regenPrefix) {
+			config.game.SetRegen(strings.TrimPrefix(param, regenPrefix) != "0")
+		} else if strings.HasPrefix(param, rewindPrefix) {
+			millis, err := strconv.Atoi(strings.TrimPrefix(param, rewindPrefix))
+			if err != nil || millis < 0 || millis > maxRewindMillis {
+				log.Printf("Invalid rewind: %s", param)
+				continue
+			}
+			config.game.SetMaxRewind(time.Duration(millis) * time.Millisecond)
+		} else if strings.HasPrefix(param, mutatorsPrefix) {
+			for _, mutator := range(strings.Split(strings.TrimPrefix(param, mutatorsPrefix), ",")) {
+				if len(mutator) == 0 {
+					continue
+				}
+				if err := ApplyMutator(&config.game, mutator); err != nil {
+					log.Printf("%v", err)
+				}
+			}
+		} else if strings.HasPrefix(param, botsPrefix) {
+			bots, err := strconv.Atoi(strings.TrimPrefix(param, botsPrefix))
+			if err != nil || bots < 0 || bots > maxBots {
+				log.Printf("Invalid bot count: %s", param)
+				continue
+			}
+			config.bots = bots
+		} else if strings.HasPrefix(param, codecPrefix) {
+			compact = strings.TrimPrefix(param, codecPrefix) == "compact"
 		}
 	}
 
@@ -73,5 +145,5 @@ func newClientHandler(w http.ResponseWriter, r *http.Request) {
 	// Try to keep the socket alive?
 	ws.SetReadDeadline(time.Time{})
 
-	NewRoom(room, name, ws)
+	NewRoom(room, name, compact, config, ws)
 }
\ No newline at end of file
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+type Mutator func(config *GameConfig)
+
+// Mutators are applied in order at room creation and can be stacked, but each only once
+var mutators = map[string]Mutator {
+	"instagib": func(config *GameConfig) {
+		config.SetLoadout(sniperWeapon)
+		config.SetWeaponPickups(false)
+		config.SetDamageMultiplier(100)
+	},
+	"lowgravity": func(config *GameConfig) {
+		physics := config.Physics()
+		physics.Gravity *= 0.4
+		physics.DownAcc *= 0.4
+		physics.JumpVel *= 0.8
+		config.SetPhysics(physics)
+	},
+	"bigheads": func(config *GameConfig) {
+		config.SetHeadScale(2.5)
+	},
+	"oneshot": func(config *GameConfig) {
+		config.SetMaxHealth(1)
+		config.SetRegen(false)
+	},
+	"shields": func(config *GameConfig) {
+		config.SetShield(50, 3 * time.Second, 25)
+	},
+	"heavyarmor": func(config *GameConfig) {
+		config.SetArmorAbsorption(0.9)
+	},
+}
+
+func ApplyMutator(config *GameConfig, name string) error {
+	mutator, ok := mutators[name]
+	if !ok {
+		return fmt.Errorf("Unknown mutator %s", name)
+	}
+	for _, applied := range(config.Mutators()) {
+		if applied == name {
+			return nil
+		}
+	}
+
+	mutator(config)
+	config.AddMutator(name)
+	return nil
+}
\ No newline at end of file
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+const (
+	navNodeSpacing float64 = 1.0
+	navStepHeight float64 = 0.7
+	navSimTimestep float64 = 1.0 / 60.0
+	navMaxSimTime float64 = 3.0
+	navClearance float64 = 0.7
+)
+
+type NavEdgeType uint8
+const (
+	unknownNavEdge NavEdgeType = iota
+	walkNavEdge
+	dropNavEdge
+	jumpNavEdge
+	doubleJumpNavEdge
+	rideNavEdge
+)
+
+type NavNodeId int
+
+type NavNode struct {
+	id NavNodeId
+	pos Vec2
+	surface SpacedId
+	moving bool
+
+	// How far up a player can walk onto the node, which is the whole height for stairs
+	step float64
+}
+
+type NavEdge struct {
+	from NavNodeId
+	to NavNodeId
+	edgeType NavEdgeType
+	cost float64
+}
+
+func (n NavNode) Id() NavNodeId { return n.id }
+func (n NavNode) Pos() Vec2 { return n.pos }
+func (n NavNode) Surface() SpacedId { return n.surface }
+func (n NavNode) Moving() bool { return n.moving }
+func (n NavNode) Step() float64 { return n.step }
+
+func (e NavEdge) From() NavNodeId { return e.from }
+func (e NavEdge) To() NavNodeId { return e.to }
+func (e NavEdge) Type() NavEdgeType { return e.edgeType }
+func (e NavEdge) Cost() float64 { return e.cost }
+
+// Where a player can stand and how they can move between standing spots
+type NavGraph struct {
+	physics PhysicsConfig
+	nodes []NavNode
+	edges map[NavNodeId][]NavEdge
+
+	// Furthest across and highest up a player can get from rest with a double jump, so farther pairs are skipped
+	reach Vec2
+}
+
+func NewNavGraph(grid *Grid) *NavGraph {
+	ng := &NavGraph {
+		physics: grid.GetConfig().Physics(),
+		nodes: make([]NavNode, 0),
+		edges: make(map[NavNodeId][]NavEdge),
+		reach: NewVec2(0, 0),
+	}
+	ng.reach = ng.maxReach()
+	ng.addNodes(grid)
+	ng.addEdges(grid)
+	return ng
+}
+
+func (ng NavGraph) GetNodes() []NavNode {
+	return ng.nodes
+}
+
+func (ng NavGraph) GetEdges(id NavNodeId) []NavEdge {
+	return ng.edges[id]
+}
+
+func (ng NavGraph) NearestNode(pos Vec2) (NavNode, bool) {
+	var nearest NavNode
+	found := false
+	nearestDist := 0.0
+	for _, node := range(ng.nodes) {
+		offset := node.pos
+		offset.Sub(pos, 1.0)
+		if !found || offset.LenSquared() < nearestDist {
+			nearest = node
+			nearestDist = offset.LenSquared()
+			found = true
+		}
+	}
+	return nearest, found
+}
+
+// A* search between the nodes nearest to the two positions. Returns the edges to follow.
+func (ng NavGraph) FindPath(from Vec2, to Vec2) ([]NavEdge, bool) {
+	start, ok := ng.NearestNode(from)
+	if !ok {
+		return nil, false
+	}
+	goal, ok := ng.NearestNode(to)
+	if !ok {
+		return nil, false
+	}
+
+	costs := make(map[NavNodeId]float64)
+	previous := make(map[NavNodeId]NavEdge)
+	open := make(NavHeap, 0)
+	heap.Push(&open, &NavItem { id: start.id, priority: ng.heuristic(start.id, goal.id) })
+	costs[start.id] = 0
+
+	for len(open) > 0 {
+		current := heap.Pop(&open).(*NavItem).id
+		if current == goal.id {
+			return ng.buildPath(previous, start.id, goal.id), true
+		}
+
+		for _, edge := range(ng.edges[current]) {
+			cost := costs[current] + edge.cost
+			if existing, ok := costs[edge.to]; ok && existing <= cost {
+				continue
+			}
+			costs[edge.to] = cost
+			previous[edge.to] = edge
+			heap.Push(&open, &NavItem { id: edge.to, priority: cost + ng.heuristic(edge.to, goal.id) })
+		}
+	}
+	return nil, false
+}
+
+func (ng NavGraph) buildPath(previous map[NavNodeId]NavEdge, start NavNodeId, goal NavNodeId) []NavEdge {
+	path := make([]NavEdge, 0)
+	for current := goal; current != start; {
+		edge := previous[current]
+		path = append(path, edge)
+		current = edge.from
+	}
+
+	for i, j := 0, len(path) - 1; i < j; i, j = i + 1, j - 1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+func (ng NavGraph) heuristic(from NavNodeId, to NavNodeId) float64 {
+	offset := ng.nodes[to].pos
+	offset.Sub(ng.nodes[from].pos, 1.0)
+	return offset.Len()
+}
+
+// Walls in id order, so node ids and bot paths are the same every time the level loads
+func (ng NavGraph) sortedWalls(grid *Grid) []Object {
+	walls := make([]Object, 0, len(grid.GetObjects(wallSpace)))
+	for _, wall := range(grid.GetObjects(wallSpace)) {
+		walls = append(walls, wall)
+	}
+	sort.Slice(walls, func(i, j int) bool {
+		return walls[i].GetId() < walls[j].GetId()
+	})
+	return walls
+}
+
+func (ng *NavGraph) addNodes(grid *Grid) {
+	for _, wall := range(ng.sortedWalls(grid)) {
+		pos := wall.Pos()
+		dim := wall.Dim()
+		xmin := pos.X - dim.X / 2
+		xmax := pos.X + dim.X / 2
+
+		// Platforms moving between bounds get nodes at both ends, joined by ride edges
+		tops := []float64 { pos.Y + dim.Y / 2 }
+		moving := !wall.Vel().IsZero()
+		if w, ok := wall.(*Wall); ok && moving && w.yBounded {
+			tops = []float64 { w.ymin + dim.Y / 2, w.ymax + dim.Y / 2 }
+		}
+
+		step := navStepHeight
+		if wall.HasAttribute(stairAttribute) {
+			step = Max(step, dim.Y)
+		}
+
+		count := int(math.Max(1, math.Floor(dim.X / navNodeSpacing)))
+		spacing := dim.X / float64(count)
+		for _, top := range(tops) {
+			for i := 0; i < count; i++ {
+				point := NewVec2(xmin + spacing * (float64(i) + 0.5), top)
+				if point.X < xmin || point.X > xmax || ng.blocked(grid, point) {
+					continue
+				}
+
+				ng.nodes = append(ng.nodes, NavNode {
+					id: NavNodeId(len(ng.nodes)),
+					pos: point,
+					surface: wall.GetSpacedId(),
+					moving: moving,
+					step: step,
+				})
+			}
+		}
+	}
+}
+
+// Whether standing at the point would put the player inside a solid wall
+func (ng NavGraph) blocked(grid *Grid, point Vec2) bool {
+	head := point
+	head.Y += navClearance
+	for _, wall := range(grid.GetObjectsInRect(head, NewVec2(0, 0))) {
+		if wall.GetSpace() != wallSpace || wall.HasAttribute(platformAttribute) {
+			continue
+		}
+		if wall.Contains(head).contains {
+			return true
+		}
+	}
+	return false
+}
+
+func (ng *NavGraph) addEdges(grid *Grid) {
+	// Only pairs within reach can be joined, so search nodes sorted by x
+	byX := make([]NavNode, len(ng.nodes))
+	copy(byX, ng.nodes)
+	sort.SliceStable(byX, func(i, j int) bool {
+		return byX[i].pos.X < byX[j].pos.X
+	})
+
+	for _, from := range(ng.nodes) {
+		first := sort.Search(len(byX), func(i int) bool {
+			return byX[i].pos.X >= from.pos.X - ng.reach.X
+		})
+		for _, to := range(byX[first:]) {
+			if to.pos.X > from.pos.X + ng.reach.X {
+				break
+			}
+			if from.id == to.id {
+				continue
+			}
+			if from.surface == to.surface && from.pos.Y != to.pos.Y {
+				if from.pos.X == to.pos.X {
+					ng.addRideEdge(grid, from, to)
+				}
+				continue
+			}
+
+			if to.pos.Y - from.pos.Y > Max(ng.reach.Y, to.step) {
+				continue
+			}
+
+			edgeType := ng.classify(from, to)
+			if edgeType == unknownNavEdge {
+				continue
+			}
+			if edgeType != walkNavEdge && !ng.clearPath(grid, from.pos, to.pos) {
+				continue
+			}
+
+			offset := to.pos
+			offset.Sub(from.pos, 1.0)
+			ng.edges[from.id] = append(ng.edges[from.id], NavEdge {
+				from: from.id,
+				to: to.id,
+				edgeType: edgeType,
+				cost: offset.Len() * navEdgeCostMultiplier(edgeType),
+			})
+		}
+	}
+}
+
+// Standing still on a moving platform until it carries the player to the other end
+func (ng *NavGraph) addRideEdge(grid *Grid, from NavNode, to NavNode) {
+	wall := grid.Get(from.surface)
+	if wall == nil || wall.Vel().Y == 0 {
+		return
+	}
+
+	// Cost is the distance the player could have run in the time taken
+	duration := Abs(to.pos.Y - from.pos.Y) / Abs(wall.Vel().Y)
+	ng.edges[from.id] = append(ng.edges[from.id], NavEdge {
+		from: from.id,
+		to: to.id,
+		edgeType: rideNavEdge,
+		cost: duration * ng.physics.MaxHorizontalVel * navEdgeCostMultiplier(rideNavEdge),
+	})
+}
+
+func (ng NavGraph) classify(from NavNode, to NavNode) NavEdgeType {
+	dx := Abs(to.pos.X - from.pos.X)
+	dy := to.pos.Y - from.pos.Y
+
+	// Stairs push the player up instead of blocking them
+	if dx <= navNodeSpacing * 1.1 && dy <= to.step && -dy <= from.step {
+		return walkNavEdge
+	}
+	if dy < 0 && ng.reachable(dx, dy, 0) {
+		return dropNavEdge
+	}
+	if ng.reachable(dx, dy, 1) {
+		return jumpNavEdge
+	}
+	if ng.reachable(dx, dy, 2) {
+		return doubleJumpNavEdge
+	}
+	return unknownNavEdge
+}
+
+// Simulate movement with the same rules as Player.UpdateState and check whether the player
+// can land dy higher and dx across, starting from rest and holding a direction the whole time.
+func (ng NavGraph) reachable(dx float64, dy float64, jumps int) bool {
+	reached := false
+	ng.simulate(jumps, func(x float64, y float64, vel float64, maxY float64) bool {
+		// Landing happens while falling through the target height
+		if vel <= 0 && maxY >= dy && y <= dy {
+			reached = dx <= x
+			return true
+		}
+		return false
+	})
+	return reached
+}
+
+func (ng NavGraph) maxReach() Vec2 {
+	reach := NewVec2(0, 0)
+	ng.simulate(2, func(x float64, y float64, vel float64, maxY float64) bool {
+		reach.X = Max(reach.X, x)
+		reach.Y = Max(reach.Y, maxY)
+		return false
+	})
+	return reach
+}
+
+// Steps a jump from rest until done returns true or the time runs out
+func (ng NavGraph) simulate(jumps int, done func(x float64, y float64, vel float64, maxY float64) bool) {
+	physics := ng.physics
+	jumpTime := jumpDuration.Seconds()
+
+	x := 0.0
+	velX := 0.0
+	y := 0.0
+	maxY := 0.0
+	vel := 0.0
+	lastJump := 0.0
+	if jumps > 0 {
+		vel = physics.JumpVel
+		jumps -= 1
+	}
+
+	for t := 0.0; t < navMaxSimTime; t += navSimTimestep {
+		acc := physics.Gravity
+		if t - lastJump >= jumpTime || vel <= 0 {
+			acc += physics.DownAcc
+		}
+		vel += acc * navSimTimestep
+
+		// Jump again at the apex
+		if vel <= 0 && jumps > 0 {
+			vel = physics.JumpVel
+			lastJump = t
+			jumps -= 1
+		}
+
+		if vel < physics.MaxDownwardVel {
+			vel *= physics.MaxVelMultiplier
+		}
+		if vel > physics.MaxUpwardVel {
+			vel *= physics.MaxVelMultiplier
+		}
+		y += vel * navSimTimestep
+		maxY = Max(maxY, y)
+
+		velX += physics.SideAcc * navSimTimestep
+		if velX > physics.MaxHorizontalVel {
+			velX *= physics.MaxVelMultiplier
+		}
+		x += velX * navSimTimestep
+
+		if done(x, y, vel, maxY) {
+			return
+		}
+	}
+}
+
+func (ng NavGraph) clearPath(grid *Grid, from Vec2, to Vec2) bool {
+	from.Y += navClearance
+	to.Y += navClearance
+	offset := to
+	offset.Sub(from, 1.0)
+	line := NewLine(from, offset)
+
+	center := from
+	center.Add(offset, 0.5)
+	for _, wall := range(grid.GetObjectsInRect(center, NewVec2(Abs(offset.X), Abs(offset.Y)))) {
+		if wall.GetSpace() != wallSpace || wall.HasAttribute(platformAttribute) {
+			continue
+		}
+		if wall.Intersects(line).hit {
+			return false
+		}
+	}
+	return true
+}
+
+func navEdgeCostMultiplier(edgeType NavEdgeType) float64 {
+	switch edgeType {
+	case walkNavEdge:
+		return 1.0
+	case dropNavEdge:
+		return 1.2
+	case jumpNavEdge:
+		return 1.5
+	case doubleJumpNavEdge:
+		return 2.0
+	case rideNavEdge:
+		return 1.0
+	}
+	return 1.0
+}
+
+type NavItem struct {
+	id NavNodeId
+
+	priority float64
+	index int
+}
+
+type NavHeap []*NavItem
+
+func (nh NavHeap) Len() int { return len(nh) }
+
+func (nh NavHeap) Less(i, j int) bool {
+	return nh[i].priority < nh[j].priority
+}
+
+func (nh NavHeap) Swap(i, j int) {
+	nh[i], nh[j] = nh[j], nh[i]
+	nh[i].index = i
+	nh[j].index = j
+}
+
+func (nh *NavHeap) Push(x interface{}) {
+	n := len(*nh)
+	item := x.(*NavItem)
+	item.index = n
+	*nh = append(*nh, item)
+}
+
+func (nh *NavHeap) Pop() interface{} {
+	old := *nh
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*nh = old[0 : n-1]
+	return item
+}
\ No newline at end of file
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newTestNavGraph() *NavGraph {
+	game := NewGame(NewGameConfig(), NewTickClock())
+	game.loadLevel(testLevel)
+	game.buildNavGraph()
+	return game.getNavGraph()
+}
+
+func TestNavGraphReachable(t *testing.T) {
+	ng := newTestNavGraph()
+
+	cases := []struct {
+		name string
+		dx float64
+		dy float64
+		jumps int
+		reachable bool
+	} {
+		{"short hop", 2, 0, 1, true},
+		{"short drop", 2, -4, 0, true},
+		{"long drop", 6, -4, 0, false},
+		{"too high for one jump", 2, 4, 1, false},
+		{"high with double jump", 2, 4, 2, true},
+		// In range at max speed, but not when accelerating from rest
+		{"long jump", 7, 0, 1, false},
+		{"long double jump", 12, 0, 2, true},
+		{"too far", 20, 0, 2, false},
+	}
+
+	for _, c := range(cases) {
+		if reachable := ng.reachable(c.dx, c.dy, c.jumps); reachable != c.reachable {
+			t.Errorf("%s: reachable(%v, %v, %d) = %v, want %v", c.name, c.dx, c.dy, c.jumps, reachable, c.reachable)
+		}
+	}
+}
+
+func TestNavGraphFindPath(t *testing.T) {
+	ng := newTestNavGraph()
+
+	from := NewVec2(3, 6)
+	to := NewVec2(33, 16)
+	path, ok := ng.FindPath(from, to)
+	if !ok || len(path) == 0 {
+		t.Fatalf("No path from %v to %v", from, to)
+	}
+
+	start, _ := ng.NearestNode(from)
+	goal, _ := ng.NearestNode(to)
+	if path[0].From() != start.Id() {
+		t.Errorf("Path starts at node %d, want %d", path[0].From(), start.Id())
+	}
+	if path[len(path) - 1].To() != goal.Id() {
+		t.Errorf("Path ends at node %d, want %d", path[len(path) - 1].To(), goal.Id())
+	}
+	for i := 1; i < len(path); i++ {
+		if path[i].From() != path[i - 1].To() {
+			t.Errorf("Edge %d starts at node %d, but the previous edge ends at %d", i, path[i].From(), path[i - 1].To())
+		}
+	}
+}
+
+func TestNavGraphFindPathCheapest(t *testing.T) {
+	ng := newTestNavGraph()
+
+	// Walking along the ground is cheaper than any jump
+	path, ok := ng.FindPath(NewVec2(15, 4), NewVec2(19, 4))
+	if !ok || len(path) == 0 {
+		t.Fatalf("No path along the ground")
+	}
+	for _, edge := range(path) {
+		if edge.Type() != walkNavEdge {
+			t.Errorf("Path along the ground has edge type %d, want %d", edge.Type(), walkNavEdge)
+		}
+	}
+}
+
+func TestNavGraphStairs(t *testing.T) {
+	ng := newTestNavGraph()
+
+	// Stairs are climbed by walking, even though the top step is higher than a normal step
+	path, ok := ng.FindPath(NewVec2(15, 4), NewVec2(22, 6))
+	if !ok || len(path) == 0 {
+		t.Fatalf("No path up the stairs")
+	}
+	for _, edge := range(path) {
+		if edge.Type() != walkNavEdge {
+			t.Errorf("Path up the stairs has edge type %d, want %d", edge.Type(), walkNavEdge)
+		}
+	}
+	if top := ng.GetNodes()[path[len(path) - 1].To()].Pos(); top.Y != 6 {
+		t.Errorf("Path up the stairs ends at %v, want the top step", top)
+	}
+}
+
+func TestNavGraphMovingPlatform(t *testing.T) {
+	ng := newTestNavGraph()
+
+	// The platform at x=11 moves between y=2 and y=5, so riders stand 0.1 higher
+	bottom, top := false, false
+	for _, node := range(ng.GetNodes()) {
+		if node.Pos().X != 11 || !node.Moving() {
+			continue
+		}
+		switch {
+		case Abs(node.Pos().Y - 2.1) < 1e-6:
+			bottom = true
+		case Abs(node.Pos().Y - 5.1) < 1e-6:
+			top = true
+		default:
+			continue
+		}
+
+		rides := 0
+		for _, edge := range(ng.GetEdges(node.Id())) {
+			if edge.Type() == rideNavEdge {
+				rides++
+				if ng.GetNodes()[edge.To()].Pos().X != node.Pos().X {
+					t.Errorf("Ride edge from %v moves sideways to %v", node.Pos(), ng.GetNodes()[edge.To()].Pos())
+				}
+			}
+		}
+		if rides != 1 {
+			t.Errorf("Platform node at %v has %d ride edges, want 1", node.Pos(), rides)
+		}
+	}
+
+	if !bottom || !top {
+		t.Errorf("Missing platform nodes: bottom=%v top=%v", bottom, top)
+	}
+}
+// Bots follow node ids, so loading the same level has to give the same graph
+func TestNavGraphDeterministic(t *testing.T) {
+	expected := newTestNavGraph()
+	for i := 0; i < 5; i++ {
+		ng := newTestNavGraph()
+		if !reflect.DeepEqual(ng.nodes, expected.nodes) || !reflect.DeepEqual(ng.edges, expected.edges) {
+			t.Fatalf("Nav graph changed between loads of the same level")
+		}
+	}
+}
\ No newline at end of file
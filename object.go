@@ -45,8 +45,6 @@ type BaseObject struct {
 	Expiration
 	Attribute
 	Attachment
-
-	lastUpdateTime time.Time
 }
 
 func NewBaseObject(profile Profile) BaseObject {
@@ -57,20 +55,10 @@ func NewBaseObject(profile Profile) BaseObject {
 		Expiration: NewExpiration(),
 		Attribute: NewAttribute(),
 		Attachment: NewAttachment(profile.GetSpacedId()),
-		lastUpdateTime: time.Time{},
 	}
 	return object
 }
 
-func (o *BaseObject) PrepareUpdate(now time.Time) float64 {
-	ts := GetTimestep(now, o.lastUpdateTime)
-	if ts >= 0 {
-		o.lastUpdateTime = now
-	}
-
-	return Max(0, ts)
-}
-
 func (o BaseObject) GetProfile() Profile {
 	return o.Profile
 }
@@ -80,6 +68,7 @@ func (o BaseObject) GetAttachment() Attachment {
 }
 
 func (o *BaseObject) Preprocess(grid *Grid, now time.Time) {
+	o.Expiration.StartTTL(now)
 	o.Attachment.Preprocess(grid, now)
 }
 
@@ -102,7 +91,6 @@ func (o *BaseObject) SetData(data Data) {
 	o.Profile.SetData(data)
 	o.Association.SetData(data)
 	o.Attribute.SetData(data)
-	o.lastUpdateTime = time.Now()
 }
 
 func (o BaseObject) GetInitData() Data {
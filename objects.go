@@ -27,14 +27,13 @@ func NewBomb(init Init) *Bomb {
 }
 
 func (b *Bomb) UpdateState(grid *Grid, now time.Time) bool {
-	b.PrepareUpdate(now)
 	b.BaseObject.UpdateState(grid, now)
 
 	if isWasm {
 		return true
 	}
 
-	if b.Expired() {
+	if b.Expired(now) {
 		pos := b.Pos()
 		dim := b.Dim()
 		dim.Scale(3.6)
@@ -46,28 +45,4 @@ func (b *Bomb) UpdateState(grid *Grid, now time.Time) bool {
 		grid.Delete(b.GetSpacedId())
 	}
 	return true
-}
-
-type Pickup struct {
-	BaseObject
-}
-
-func NewPickup(init Init) *Pickup {
-	profile := NewRec2(init)
-	pickup := &Pickup {
-		BaseObject: NewBaseObject(profile),
-	}
-	return pickup
-}
-
-func (p *Pickup) SetWeaponType(weaponType WeaponType) {
-	p.SetByteAttribute(typeByteAttribute, uint8(weaponType))
-}
-
-func (p Pickup) GetWeaponType() WeaponType {
-	typeByte, ok := p.GetByteAttribute(typeByteAttribute)
-	if !ok {
-		return unknownWeapon
-	}
-	return WeaponType(typeByte)
 }
\ No newline at end of file
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+type PickupType uint8
+const (
+	unknownPickup PickupType = iota
+	weaponPickup
+	healthPickup
+	armorPickup
+	ammoPickup
+	powerUpPickup
+)
+
+type Pickup struct {
+	BaseObject
+
+	consumeOnTouch bool
+	respawnTimer Timer
+}
+
+func NewPickup(init Init) *Pickup {
+	profile := NewRec2(init)
+	pickup := &Pickup {
+		BaseObject: NewBaseObject(profile),
+
+		consumeOnTouch: false,
+		respawnTimer: NewTimer(0),
+	}
+	return pickup
+}
+
+func (p *Pickup) SetPickupType(pickupType PickupType) {
+	p.SetByteAttribute(pickupByteAttribute, uint8(pickupType))
+	p.consumeOnTouch = pickupType != weaponPickup
+}
+
+func (p Pickup) GetPickupType() PickupType {
+	typeByte, ok := p.GetByteAttribute(pickupByteAttribute)
+	if !ok {
+		return unknownPickup
+	}
+	return PickupType(typeByte)
+}
+
+func (p *Pickup) SetWeaponType(weaponType WeaponType) {
+	p.SetPickupType(weaponPickup)
+	p.SetByteAttribute(typeByteAttribute, uint8(weaponType))
+}
+
+func (p Pickup) GetWeaponType() WeaponType {
+	typeByte, ok := p.GetByteAttribute(typeByteAttribute)
+	if !ok {
+		return unknownWeapon
+	}
+	return WeaponType(typeByte)
+}
+
+func (p *Pickup) SetPowerUpType(powerUp PowerUpType) {
+	p.SetPickupType(powerUpPickup)
+	p.SetByteAttribute(typeByteAttribute, uint8(powerUp))
+}
+
+func (p Pickup) GetPowerUpType() PowerUpType {
+	typeByte, ok := p.GetByteAttribute(typeByteAttribute)
+	if !ok {
+		return unknownPowerUp
+	}
+	return PowerUpType(typeByte)
+}
+
+// Amount of health, armor, etc. granted by the pickup
+func (p *Pickup) SetAmount(amount int) {
+	p.SetByteAttribute(amountByteAttribute, uint8(amount))
+}
+
+func (p Pickup) GetAmount() int {
+	amount, ok := p.GetByteAttribute(amountByteAttribute)
+	if !ok {
+		return 0
+	}
+	return int(amount)
+}
+
+func (p *Pickup) SetConsumeOnTouch(consumeOnTouch bool) {
+	p.consumeOnTouch = consumeOnTouch
+}
+
+func (p Pickup) ConsumeOnTouch() bool {
+	return p.consumeOnTouch
+}
+
+// Pickup is hidden for the respawn time after use. Zero means the pickup is never consumed.
+func (p *Pickup) SetRespawnTime(respawnTime time.Duration) {
+	p.respawnTimer.SetDuration(respawnTime)
+}
+
+func (p Pickup) Available() bool {
+	return !p.HasAttribute(hiddenAttribute)
+}
+
+func (p *Pickup) Consume(now time.Time) {
+	if isWasm || p.respawnTimer.duration == 0 {
+		return
+	}
+
+	p.AddAttribute(hiddenAttribute)
+	p.respawnTimer.Start(now)
+	p.updateRespawnSeconds(now)
+}
+
+func (p *Pickup) UpdateState(grid *Grid, now time.Time) bool {
+	if isWasm || p.Available() {
+		return false
+	}
+
+	if !p.respawnTimer.On(now) {
+		p.RemoveAttribute(hiddenAttribute)
+		p.SetByteAttribute(timerByteAttribute, 0)
+		return false
+	}
+
+	p.updateRespawnSeconds(now)
+	return false
+}
+
+func (p *Pickup) updateRespawnSeconds(now time.Time) {
+	seconds := math.Ceil(p.respawnTimer.Remaining(now).Seconds())
+	p.SetByteAttribute(timerByteAttribute, uint8(Min(seconds, math.MaxUint8)))
+}
\ No newline at end of file
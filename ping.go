@@ -0,0 +1,78 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	serverPingInterval time.Duration = 500 * time.Millisecond
+
+	// Round trips averaged for the latency estimate
+	maxPingSamples int = 4
+	// Pings lost on the way are forgotten once this many newer ones are outstanding
+	maxPendingPings int = 8
+)
+
+// Measures a client's round trip time with pings it echoes straight back, so lag compensation
+// doesn't depend on what the client reports
+type Pinger struct {
+	seqNum SeqNumType
+	lastPing time.Time
+	pending map[SeqNumType]time.Time
+	samples []time.Duration
+}
+
+func NewPinger() *Pinger {
+	return &Pinger {
+		seqNum: 0,
+		lastPing: time.Time{},
+		pending: make(map[SeqNumType]time.Time),
+		samples: make([]time.Duration, 0, maxPingSamples),
+	}
+}
+
+// Returns a ping to send if one is due
+func (p *Pinger) Ping(now time.Time) (PingMsg, bool) {
+	if now.Sub(p.lastPing) < serverPingInterval {
+		return PingMsg{}, false
+	}
+
+	p.seqNum++
+	p.lastPing = now
+	p.pending[p.seqNum] = now
+	delete(p.pending, p.seqNum - SeqNumType(maxPendingPings))
+
+	return PingMsg {
+		T: pingType,
+		S: p.seqNum,
+		E: true,
+	}, true
+}
+
+// Records the round trip for an echoed ping. Returns false if it wasn't one we're waiting for.
+func (p *Pinger) Pong(seqNum SeqNumType, now time.Time) bool {
+	sent, ok := p.pending[seqNum]
+	if !ok {
+		return false
+	}
+	delete(p.pending, seqNum)
+
+	if len(p.samples) >= maxPingSamples {
+		p.samples = p.samples[1:]
+	}
+	p.samples = append(p.samples, now.Sub(sent))
+	return true
+}
+
+// Mean of the recent round trips
+func (p *Pinger) RTT() time.Duration {
+	if len(p.samples) == 0 {
+		return 0
+	}
+
+	var total time.Duration
+	for _, sample := range(p.samples) {
+		total += sample
+	}
+	return total / time.Duration(len(p.samples))
+}
\ No newline at end of file
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+	"log"
+	"math"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	replayEndpoint string = "/replay/"
+
+	// Spectators have no player object
+	spectatorId IdType = math.MaxUint16
+
+	minPlaybackSpeed float64 = 0.25
+	maxPlaybackSpeed float64 = 8
+)
+
+// Streams a capture to a single spectator with the normal client protocol.
+// Controls are chat commands: /pause, /play, /speed x, /seek [+-]seconds
+type Playback struct {
+	capture *Capture
+	cursor *CaptureCursor
+	client *Client
+
+	init chan *Client
+	incoming chan IncomingMsg
+	unregister chan *Client
+	ticker *time.Ticker
+
+	started bool
+	paused bool
+	speed float64
+	position float64
+	next int
+
+	// Messages are rewritten so the client always sees increasing sequence numbers
+	seqNum SeqNumType
+	level []byte
+	known map[SpacedId]bool
+
+	// Frames only store what changed, so the full state is rebuilt here and resent every tick
+	state ObjectPropMap
+	// Objects that didn't fit in the last state message, which go first in the next one
+	overflow []SpacedId
+
+	// Max bytes per state message on the unreliable data channel
+	chunkSize int
+	webRTC WebRTCConfig
+}
+
+func NewPlayback(capture *Capture, ws *websocket.Conn, name string, chunkSize int, webRTC WebRTCConfig) *Playback {
+	p := &Playback {
+		capture: capture,
+		cursor: NewCaptureCursor(capture),
+
+		init: make(chan *Client),
+		incoming: make(chan IncomingMsg),
+		unregister: make(chan *Client),
+		ticker: time.NewTicker(frameTime),
+
+		started: false,
+		paused: false,
+		speed: 1,
+		position: 0,
+		next: 0,
+
+		seqNum: 0,
+		level: nil,
+		known: make(map[SpacedId]bool),
+
+		state: make(ObjectPropMap),
+		overflow: make([]SpacedId, 0),
+
+		chunkSize: chunkSize,
+		webRTC: webRTC,
+	}
+	p.client = NewClient(p, spectatorId, ws, name, false)
+	go p.run()
+	return p
+}
+
+func (p *Playback) Incoming() chan<- IncomingMsg { return p.incoming }
+func (p *Playback) Unregister() chan<- *Client { return p.unregister }
+
+func (p *Playback) run() {
+	defer func() {
+		p.ticker.Stop()
+		p.cursor.Close()
+		p.client.Close()
+		log.Printf("Stopped playback of %s for %s", p.capture.Room(), p.client.GetDisplayName())
+	}()
+
+	if err := p.register(); err != nil {
+		log.Printf("Failed to register spectator %s: %v", p.client.GetDisplayName(), err)
+		return
+	}
+
+	for {
+		select {
+		case <-p.init:
+			p.start()
+		case imsg := <-p.incoming:
+			msg := Msg{}
+			if err := Unpack(imsg.b, &msg); err != nil {
+				log.Printf("error unpacking: %v", err)
+				continue
+			}
+			p.processMsg(msg)
+		case <-p.unregister:
+			return
+		case <-p.ticker.C:
+			p.advance()
+		}
+	}
+}
+
+func (p *Playback) register() error {
+	err := p.client.InitWebRTC(p.webRTC, func() {
+		p.init <- p.client
+	})
+	if err != nil {
+		return err
+	}
+
+	msg := ClientMsg {
+		T: initType,
+		Client: p.client.GetClientData(),
+		Clients: map[IdType]ClientData {
+			spectatorId: p.client.GetClientData(),
+		},
+		WebRTC: p.webRTC.Msg(strconv.Itoa(int(spectatorId))),
+	}
+	return p.client.Send(&msg)
+}
+
+func (p *Playback) start() {
+	playerInitMsg := PlayerInitMsg {
+		T: playerInitType,
+		Id: spectatorId,
+		Ps: make(PlayerPropMap),
+	}
+
+	p.seek(0)
+	p.client.Send(&playerInitMsg)
+	p.started = true
+	log.Printf("Started playback of %s for %s", p.capture.Room(), p.client.GetDisplayName())
+}
+
+func (p *Playback) processMsg(msg Msg) {
+	var err error
+
+	switch(msg.T) {
+	case pingType:
+		outMsg := PingMsg {
+			T: pingType,
+			S: msg.Ping.S,
+		}
+		p.client.Send(&outMsg)
+	case offerType:
+		err = p.client.processWebRTCOffer(msg.JSON)
+	case candidateType:
+		err = p.client.processWebRTCCandidate(msg.JSON)
+	case chatType:
+		p.processCommand(msg.Chat.M)
+	case keyType:
+	default:
+		log.Printf("Unknown message type %d during playback", msg.T)
+	}
+
+	if err != nil {
+		log.Printf("error when processing message: %v", err)
+	}
+}
+
+func (p *Playback) processCommand(command string) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return
+	}
+
+	switch fields[0] {
+	case "/pause":
+		p.paused = true
+	case "/play":
+		if p.next >= p.capture.NumFrames() {
+			p.seek(0)
+		}
+		p.paused = false
+	case "/speed":
+		if len(fields) < 2 {
+			break
+		}
+		speed, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			p.reply("Invalid speed: " + fields[1])
+			return
+		}
+		p.speed = Clamp(minPlaybackSpeed, speed, maxPlaybackSpeed)
+	case "/seek":
+		if len(fields) < 2 {
+			break
+		}
+		seconds, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			p.reply("Invalid time: " + fields[1])
+			return
+		}
+
+		frames := int(seconds * float64(time.Second) / float64(frameTime))
+		if strings.HasPrefix(fields[1], "+") || strings.HasPrefix(fields[1], "-") {
+			frames += p.next
+		}
+		p.seek(frames)
+	default:
+		p.reply("Commands: /pause, /play, /speed x, /seek [+-]seconds")
+		return
+	}
+
+	p.reply(p.status())
+}
+
+func (p *Playback) status() string {
+	state := "playing"
+	if p.paused {
+		state = "paused"
+	}
+	elapsed := time.Duration(p.next) * frameTime
+	total := time.Duration(p.capture.NumFrames()) * frameTime
+	return fmt.Sprintf("%s %v / %v at %.2gx", state, elapsed.Round(time.Second), total.Round(time.Second), p.speed)
+}
+
+func (p *Playback) reply(message string) {
+	msg := ChatMsg {
+		T: chatType,
+		Id: spectatorId,
+		M: message,
+	}
+	p.client.Send(&msg)
+}
+
+func (p *Playback) advance() {
+	if !p.started {
+		return
+	}
+	if p.paused {
+		if len(p.overflow) > 0 {
+			p.sendState()
+		}
+		return
+	}
+
+	p.position += p.speed
+	applied := false
+	for p.next < p.capture.NumFrames() && float64(p.next) < p.position {
+		frame, err := p.cursor.Next()
+		if err != nil {
+			log.Printf("Failed to read frame %d of %s: %v", p.next, p.capture.Room(), err)
+			p.next = p.capture.NumFrames()
+			break
+		}
+		p.apply(frame)
+		applied = true
+		p.next++
+	}
+
+	// Only the latest state matters when playing faster than real time
+	if applied {
+		p.sendState()
+	}
+
+	if p.next >= p.capture.NumFrames() {
+		p.paused = true
+		p.reply("Replay finished, /play to restart")
+	}
+}
+
+// Jump to a frame by loading the nearest keyframe and applying the frames in between
+func (p *Playback) seek(index int) {
+	index = int(Clamp(0, float64(index), float64(p.capture.NumFrames() - 1)))
+	keyIndex, err := p.cursor.SeekKeyframe(index)
+	if err != nil {
+		log.Printf("Failed to seek to frame %d of %s: %v", index, p.capture.Room(), err)
+		return
+	}
+	keyframe, err := p.cursor.Next()
+	if err != nil {
+		log.Printf("Failed to read keyframe %d of %s: %v", keyIndex, p.capture.Room(), err)
+		return
+	}
+
+	if !bytes.Equal(keyframe.Level, p.level) {
+		// Client clears all objects when loading a level
+		p.level = keyframe.Level
+		p.known = make(map[SpacedId]bool)
+		p.client.SendBytes(keyframe.Level)
+	}
+
+	init := GameStateMsg{}
+	if err := unpackState(keyframe.Init, &init); err != nil {
+		log.Printf("Bad keyframe at %d: %v", keyIndex, err)
+		return
+	}
+	p.deleteMissing(init.Os)
+	p.send(init, false)
+
+	p.state = make(ObjectPropMap)
+	p.apply(keyframe)
+	for p.cursor.Index() <= index {
+		frame, err := p.cursor.Next()
+		if err != nil {
+			log.Printf("Failed to read frame %d of %s: %v", p.cursor.Index(), p.capture.Room(), err)
+			break
+		}
+		p.apply(frame)
+	}
+	p.sendState()
+
+	p.next = p.cursor.Index()
+	p.position = float64(p.next)
+}
+
+// Delete objects the client has seen that don't exist in the snapshot
+func (p *Playback) deleteMissing(objects ObjectPropMap) {
+	msg := GameStateMsg {
+		T: objectUpdateType,
+		Os: make(ObjectPropMap),
+	}
+
+	for sid := range(p.known) {
+		if _, ok := objects[sid.S][sid.Id]; ok {
+			continue
+		}
+		if _, ok := msg.Os[sid.S]; !ok {
+			msg.Os[sid.S] = make(SpacedPropMap)
+		}
+		msg.Os[sid.S][sid.Id] = PropMap { deletedProp: true }
+	}
+
+	if len(msg.Os) > 0 {
+		p.send(msg, false)
+	}
+}
+
+// Merges the frame's state into the full state and sends its updates
+func (p *Playback) apply(frame StateFrame) {
+	state := GameStateMsg{}
+	if err := unpackState(frame.State, &state); err != nil {
+		log.Printf("Bad frame at tick %d: %v", frame.Tick, err)
+	} else {
+		for space, objects := range(state.Os) {
+			if _, ok := p.state[space]; !ok {
+				p.state[space] = make(SpacedPropMap)
+			}
+			for id, props := range(objects) {
+				if _, ok := p.state[space][id]; !ok {
+					p.state[space][id] = make(PropMap)
+				}
+				for prop, data := range(props) {
+					p.state[space][id][prop] = data
+				}
+			}
+		}
+	}
+
+	if len(frame.Update) == 0 {
+		return
+	}
+
+	update := GameStateMsg{}
+	if err := unpackState(frame.Update, &update); err != nil {
+		log.Printf("Bad frame at tick %d: %v", frame.Tick, err)
+		return
+	}
+
+	// Deleted objects are still in the state for the tick they're deleted on
+	for space, objects := range(update.Os) {
+		for id, props := range(objects) {
+			if deleted, ok := props[deletedProp].(bool); ok && deleted {
+				delete(p.state[space], id)
+			}
+		}
+	}
+	p.send(update, false)
+}
+
+func (p *Playback) sendState() {
+	msg := GameStateMsg {
+		T: objectDataType,
+		Os: p.state,
+	}
+	p.send(msg, true)
+}
+
+func (p *Playback) send(msg GameStateMsg, udp bool) {
+	p.seqNum++
+	msg.S = p.seqNum
+
+	for space, objects := range(msg.Os) {
+		for id, props := range(objects) {
+			sid := Id(space, id)
+			if deleted, ok := props[deletedProp].(bool); ok && deleted {
+				delete(p.known, sid)
+			} else {
+				p.known[sid] = true
+			}
+		}
+	}
+
+	if udp {
+		chunker := NewStateChunker(msg.S, p.chunkSize)
+		overflow := make([]SpacedId, 0)
+		add := func(sid SpacedId, props PropMap) {
+			if !chunker.Add(sid, props, PackedObjectSize(sid, props)) {
+				overflow = append(overflow, sid)
+			}
+		}
+
+		carried := make(map[SpacedId]bool, len(p.overflow))
+		for _, sid := range(p.overflow) {
+			carried[sid] = true
+			if props, ok := msg.Os[sid.S][sid.Id]; ok {
+				add(sid, props)
+			}
+		}
+		for space, objects := range(msg.Os) {
+			for id, props := range(objects) {
+				sid := Id(space, id)
+				if !carried[sid] {
+					add(sid, props)
+				}
+			}
+		}
+		p.overflow = overflow
+
+		for _, chunk := range(chunker.Msgs()) {
+			p.client.SendUDP(&chunk)
+		}
+	} else {
+		p.client.Send(&msg)
+	}
+}
+
+// Nested maps in props can have integer keys, so decode them untyped
+func unpackState(b []byte, msg *GameStateMsg) error {
+	decoder := msgpack.NewDecoder(bytes.NewReader(b))
+	decoder.SetMapDecoder(func(d *msgpack.Decoder) (interface{}, error) {
+		return d.DecodeUntypedMap()
+	})
+	return decoder.Decode(msg)
+}
+
+func replayHandler(w http.ResponseWriter, r *http.Request) {
+	const (
+		filePrefix string = "file="
+		namePrefix string = "name="
+	)
+	var file string
+	var name string
+	for _, param := range strings.Split(r.URL.Path[len(replayEndpoint):], "&") {
+		if strings.HasPrefix(param, filePrefix) {
+			file = strings.TrimPrefix(param, filePrefix)
+		} else if strings.HasPrefix(param, namePrefix) {
+			name = strings.TrimPrefix(param, namePrefix)
+		}
+	}
+
+	dir := serverConfig.captureDir
+	if len(dir) == 0 {
+		log.Printf("Replay requested but CAPTURE_DIR is not set")
+		return
+	}
+
+	if len(file) == 0 || filepath.Base(file) != file || strings.HasPrefix(file, ".") {
+		log.Printf("Invalid replay file: %s", file)
+		return
+	}
+	if !strings.HasSuffix(file, captureExt) {
+		file += captureExt
+	}
+
+	name = strings.TrimSpace(name)
+	if len(name) == 0 || len(name) > 16 {
+		log.Printf("Name %s should be 1-16 chars long", name)
+		return
+	}
+
+	capture, err := GetCapture(filepath.Join(dir, file))
+	if err != nil {
+		log.Printf("Failed to load capture %s: %v", file, err)
+		return
+	}
+
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Failed to create websocket: %v", err)
+		return
+	}
+	ws.SetReadDeadline(time.Time{})
+
+	NewPlayback(capture, ws, name, serverConfig.stateChunkSize, serverConfig.webRTC)
+}
\ No newline at end of file
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"math/rand"
 	"time"
 )
 
@@ -9,6 +8,7 @@ const (
 	sqrtHalf float64 = .7071
 )
 
+// Defaults for PhysicsConfig
 const (
 	gravityAcc = -18.0
 	downAcc = -18.0
@@ -29,22 +29,33 @@ const (
 	friction = 0.4
 	knockbackFriction = 0.9
 	airResistance = 0.9
+)
 
+const (
 	jumpDuration time.Duration = 300 * time.Millisecond
 	jumpGraceDuration time.Duration = 100 * time.Millisecond
 	knockbackDuration time.Duration = 150 * time.Millisecond
+	deathDuration time.Duration = 1 * time.Second
 
 	bodySubProfile ProfileKey = 1
 	bodySubProfileOffsetY = 0.22
+	headOffsetY = 0.6
+	headDiameter = 0.6
 )
 
 type Player struct {
 	BaseObject
 	Keys
 	weapon *Weapon
+	stats Stats
+	// Enlarged head that only counts for hits
+	head *Circle
 
 	canJump bool
-	canDoubleJump bool
+	airJumps int
+
+	// Round trip time reported by the client
+	latency time.Duration
 
 	jumpTimer Timer
 	jumpGraceTimer Timer
@@ -77,41 +88,95 @@ func NewPlayer(init Init) *Player {
 		BaseObject: NewBaseObject(profile),
 		Keys: NewKeys(),
 		weapon: nil,
+		stats: NewStats(),
+		head: nil,
 
 		canJump: false,
-		canDoubleJump: true,
+		airJumps: 1,
+
+		latency: 0,
 
 		jumpTimer: NewTimer(jumpDuration),
 		jumpGraceTimer: NewTimer(jumpGraceDuration),
 		knockbackTimer: NewTimer(knockbackDuration),
-		deathTimer: NewTimer(1 * time.Second),
+		deathTimer: NewTimer(deathDuration),
 	}
-	player.Respawn()
 	return player
 }
 
+func (p Player) GetInitData() Data {
+	data := p.BaseObject.GetInitData()
+	data.Merge(p.stats.GetInitData())
+	return data
+}
+
 func (p Player) GetData() Data {
 	data := p.BaseObject.GetData()
+	data.Merge(p.stats.GetData())
 	data.Set(keysProp, p.GetKeys())
+	data.Set(keySeqNumProp, p.LastSeqNum())
 	return data
 }
 
+func (p Player) GetUpdates() Data {
+	updates := p.BaseObject.GetUpdates()
+	updates.Merge(p.stats.GetUpdates())
+	return updates
+}
+
 func (p *Player) SetData(data Data) {
 	if data.Size() == 0 {
 		return
 	}
 	p.BaseObject.SetData(data)
+	p.stats.SetData(data)
 	if data.Has(keysProp) {
 		p.SetKeys(data.Get(keysProp).(map[KeyType]bool))
 	}
 }
 
+// Enlarge the hitbox around the head. It's kept out of the player's own collisions so movement is unchanged.
+// Scales of 1 or less do nothing, since the body already covers a normal head.
+func (p *Player) SetHeadScale(scale float64) {
+	if scale <= 1 {
+		p.head = nil
+		return
+	}
+
+	diameter := headDiameter * scale
+	p.head = NewCircle(NewObjectInit(p.GetSpacedId(), p.Pos(), NewVec2(diameter, diameter)))
+}
+
+// Area outside the body that counts for hits, or nil if there is none
+func (p *Player) HitProfile() Profile {
+	if p.head == nil {
+		return nil
+	}
+
+	pos := p.Pos()
+	pos.Add(NewVec2(0, headOffsetY), 1.0)
+	p.head.SetPos(pos)
+	return p.head
+}
+
+func (p *Player) AddPowerUp(powerUp PowerUpType, duration time.Duration) {
+	p.stats.AddPowerUp(powerUp, duration)
+}
+
+func (p Player) Latency() time.Duration {
+	return p.latency
+}
+
+func (p *Player) SetLatency(latency time.Duration) {
+	p.latency = latency
+}
+
 func (p Player) Dead() bool {
 	return p.Health.Dead()
 }
 
 func (p Player) UpdateScore(g *Grid) {
-	sid := p.Health.GetLastDamageId(lastDamageTime)
+	sid := p.Health.GetLastDamageId(lastDamageTime, g.Now())
 	g.IncrementScore(p.GetSpacedId(), deathProp, 1)
 
 	if sid.Invalid() {
@@ -121,41 +186,61 @@ func (p Player) UpdateScore(g *Grid) {
 	g.IncrementScore(sid, killProp, 1)
 }
 
-func (p *Player) Respawn() {
+func (p *Player) Respawn(grid *Grid) {
 	p.Health.Respawn()
 
-	p.SetHealth(100)
+	p.SetHealth(p.GetMaxHealth())
 	p.RemoveAttribute(groundedAttribute)
-	p.canDoubleJump = true
+	p.stats.ClearPowerUps()
+	p.airJumps = p.stats.AirJumps()
 
-	rand.Seed(time.Now().Unix())
-	p.SetPos(NewVec2(float64(15 + rand.Intn(15)), 20))
+	p.SetPos(NewVec2(float64(15 + grid.Rand().Intn(15)), 20))
 	p.SetVel(NewVec2(0, 0))
 	p.SetAcc(NewVec2(0, 0))
 }
 
 func (p *Player) UpdateState(grid *Grid, now time.Time) bool {
-	ts := p.PrepareUpdate(now)
+	ts := grid.Timestep()
 	p.BaseObject.UpdateState(grid, now)
+	p.stats.SetPhysics(grid.GetConfig().Physics())
+	p.stats.UpdatePowerUps(grid.Timestep())
+	physics := p.stats.Physics()
 
 	// Handle health stuff
 	if p.Pos().Y < -5 {
 		p.Die()
 	}
 
-	p.SetByteAttribute(healthByteAttribute, uint8(p.GetHealth()))
+	config := grid.GetConfig()
+	if !isWasm && p.weapon == nil && config.Loadout() != unknownWeapon {
+		p.equipWeapon(grid, config.Loadout())
+	}
+	p.SetMaxHealth(config.MaxHealth())
+	if config.Regen() {
+		p.Regenerate(now, ts, config.RegenDelay(), config.RegenRate())
+	}
+	p.RechargeShield(now, ts)
+	p.SetByteAttribute(healthByteAttribute, ClampByte(p.GetHealth()))
+	p.SetByteAttribute(armorByteAttribute, ClampByte(p.GetArmor()))
+	if p.HasShield() {
+		p.SetByteAttribute(shieldByteAttribute, ClampByte(p.GetShield()))
+	}
 	if p.Dead() {
 		if !p.HasAttribute(deadAttribute) {
 			p.AddAttribute(deadAttribute)
 			p.Keys.SetEnabled(false)
-			p.deathTimer.Start()
+			p.deathTimer.Start(now)
 			p.UpdateScore(grid)
 		}
 
-		if !p.deathTimer.On() {
+		if !p.deathTimer.On(now) {
 			p.RemoveAttribute(deadAttribute)
 			p.Keys.SetEnabled(true)
-			p.Respawn()
+			p.Respawn(grid)
+
+			if !isWasm && config.Loadout() != unknownWeapon {
+				p.equipWeapon(grid, config.Loadout())
+			}
 		}
 	}
 
@@ -165,9 +250,9 @@ func (p *Player) UpdateState(grid *Grid, now time.Time) bool {
 	pos := p.Pos()
 
 	if grounded {
-		p.jumpGraceTimer.Start()
+		p.jumpGraceTimer.Start(now)
 		p.canJump = true
-		p.canDoubleJump = true
+		p.airJumps = p.stats.AirJumps()
 
 		if p.weapon != nil {
 			p.weapon.OnGrounded()
@@ -176,22 +261,22 @@ func (p *Player) UpdateState(grid *Grid, now time.Time) bool {
 
 
 	// Gravity & air resistance
-	acc.Y = gravityAcc
+	acc.Y = p.stats.Gravity()
 	if !grounded {
-		if !p.jumpTimer.On() || vel.Y <= 0 {
-			acc.Y += downAcc
+		if !p.jumpTimer.On(now) || vel.Y <= 0 {
+			acc.Y += p.stats.DownAcc()
 		}
 	}
 
 	// Left & right
 	if p.KeyDown(leftKey) != p.KeyDown(rightKey) {
 		if p.KeyDown(leftKey) {
-			acc.X = leftAcc
+			acc.X = -p.stats.SideAcc()
 		} else {
-			acc.X = rightAcc
+			acc.X = p.stats.SideAcc()
 		}
 		if Sign(acc.X) == -Sign(vel.X) {
-			acc.X *= turnMultiplier
+			acc.X *= physics.TurnMultiplier
 		}
 	} else {
 		acc.X = 0
@@ -203,51 +288,51 @@ func (p *Player) UpdateState(grid *Grid, now time.Time) bool {
 
 	// Jump & double jump
 	if p.KeyDown(jumpKey) {
-		if p.canJump && p.jumpGraceTimer.On() {
+		if p.canJump && p.jumpGraceTimer.On(now) {
 			p.canJump = false
-			vel.Y = Max(0, vel.Y) + jumpVel
-			p.jumpTimer.Start()
-		} else if p.KeyPressed(jumpKey) && p.canDoubleJump {
-			vel.Y = jumpVel
-			p.canDoubleJump = false
-			p.jumpTimer.Start()
+			vel.Y = Max(0, vel.Y) + p.stats.JumpVel()
+			p.jumpTimer.Start(now)
+		} else if p.KeyPressed(jumpKey) && p.airJumps > 0 {
+			vel.Y = p.stats.JumpVel()
+			p.airJumps -= 1
+			p.jumpTimer.Start(now)
 		}
 	}
 
 	p.SetVel(vel)
-	if force := p.ApplyForces(); force.LenSquared() > knockbackForceSquared {
-		p.knockbackTimer.Start()
+	if force := p.ApplyForces(); force.LenSquared() > physics.KnockbackForceSquared {
+		p.knockbackTimer.Start(now)
 	}
 	vel = p.Vel()
 
 	// Friction
 	if grounded {
 		if Sign(acc.X) != Sign(vel.X) {
-			if p.knockbackTimer.On() {
-				vel.X *= p.knockbackTimer.Lerp(knockbackFriction, friction)
+			if p.knockbackTimer.On(now) {
+				vel.X *= p.knockbackTimer.Lerp(now, physics.KnockbackFriction, physics.Friction)
 			} else {
-				vel.X *= friction
+				vel.X *= physics.Friction
 			}
 		}
 	} else {
 		if acc.X == 0 {
-			vel.X *= airResistance
+			vel.X *= physics.AirResistance
 		}
 	}
 
-	if Abs(vel.X) > maxHorizontalVel {
-		vel.X *= maxVelMultiplier
+	if Abs(vel.X) > p.stats.MaxHorizontalVel() {
+		vel.X *= physics.MaxVelMultiplier
 	}
-	if vel.Y < maxDownwardVel {
-		vel.Y *= maxVelMultiplier
+	if vel.Y < physics.MaxDownwardVel {
+		vel.Y *= physics.MaxVelMultiplier
 	}
-	if vel.Y > maxUpwardVel {
-		vel.Y *= maxVelMultiplier
+	if vel.Y > physics.MaxUpwardVel {
+		vel.Y *= physics.MaxVelMultiplier
 	}
 
-	if vel.LenSquared() >= maxSpeed * maxSpeed {
+	if vel.LenSquared() >= physics.MaxSpeed * physics.MaxSpeed {
 		vel.Normalize()
-		vel.Scale(maxSpeed)
+		vel.Scale(physics.MaxSpeed)
 	}
 	p.SetVel(vel)
 
@@ -284,21 +369,65 @@ func (p *Player) checkCollisions(grid *Grid) {
 		collider := PopObject(&colliders)
 		switch object := collider.(type) {
 		case *Pickup:
-			if !isWasm && p.KeyDown(interactKey) {
-				if p.weapon == nil {
-					weapon := grid.New(NewObjectInit(grid.NextSpacedId(weaponSpace), p.Pos(), p.Dim()))
-					grid.Upsert(weapon)
-					p.weapon = weapon.(*Weapon)
-					p.weapon.AddConnection(p.GetSpacedId(), NewOffsetConnection(NewVec2(0, bodySubProfileOffsetY)))
-					p.weapon.SetOwner(p.GetSpacedId())
-				}
+			if isWasm || !object.Available() {
+				break
+			}
 
-				p.weapon.SetWeaponType(object.GetWeaponType())
+			if object.ConsumeOnTouch() || p.KeyDown(interactKey) {
+				if p.usePickup(grid, object) {
+					object.Consume(grid.Now())
+				}
 			}
 		}
 	}
 }
 
+// Returns whether the pickup had any effect
+func (p *Player) usePickup(grid *Grid, pickup *Pickup) bool {
+	switch pickup.GetPickupType() {
+	case weaponPickup:
+		if !grid.GetConfig().WeaponPickups() {
+			return false
+		}
+		p.equipWeapon(grid, pickup.GetWeaponType())
+		return true
+	case healthPickup:
+		if p.GetHealth() >= p.GetMaxHealth() {
+			return false
+		}
+		p.Heal(pickup.GetAmount())
+		return true
+	case armorPickup:
+		if p.GetArmor() >= maxArmor {
+			return false
+		}
+		p.AddArmor(pickup.GetAmount())
+		return true
+	case ammoPickup:
+		if p.weapon == nil {
+			return false
+		}
+		p.weapon.Reload()
+		return true
+	case powerUpPickup:
+		p.AddPowerUp(pickup.GetPowerUpType(), time.Duration(pickup.GetAmount()) * time.Second)
+		return true
+	}
+	return false
+}
+
+func (p *Player) equipWeapon(grid *Grid, weaponType WeaponType) {
+	if p.weapon == nil {
+		weapon := grid.New(NewObjectInit(grid.NextSpacedId(weaponSpace), p.Pos(), p.Dim()))
+		grid.Upsert(weapon)
+		p.weapon = weapon.(*Weapon)
+		p.weapon.AddConnection(p.GetSpacedId(), NewOffsetConnection(NewVec2(0, bodySubProfileOffsetY)))
+		p.weapon.SetOwner(p.GetSpacedId())
+	}
+
+	p.weapon.SetWeaponType(weaponType)
+}
+
 func (p *Player) UpdateKeys(keyMsg KeyMsg) {
 	p.Keys.UpdateKeys(keyMsg)
 	if p.weapon != nil {
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestBigHeadOnlyCountsForHits(t *testing.T) {
+	for _, bigheads := range([]bool { false, true }) {
+		config := NewGameConfig()
+		if bigheads {
+			ApplyMutator(&config, "bigheads")
+		}
+		game := NewGame(config, NewManualClock())
+		game.loadLevel(testLevel)
+
+		// In the air above the level, with a ceiling just over the body and a shot just over that
+		player := game.addPlayer(1).(*Player)
+		player.SetPos(NewVec2(10, 30))
+		game.grid.Upsert(player)
+		ceiling := game.add(NewObjectInit(game.grid.NextSpacedId(wallSpace), NewVec2(10, 31), NewVec2(2, 0.4)))
+		pellet := NewPellet(NewObjectInit(game.grid.NextSpacedId(pelletSpace), NewVec2(10, 31.25), NewVec2(0.2, 0.2)))
+
+		for _, collider := range(game.grid.GetColliders(player)) {
+			if collider.object.GetSpacedId() == ceiling.GetSpacedId() {
+				t.Errorf("Player collided with a wall over its head with bigheads=%v", bigheads)
+			}
+		}
+		if hit := OverlapHit(pellet, player).hit; hit != bigheads {
+			t.Errorf("Shot over the body hit=%v with bigheads=%v", hit, bigheads)
+		}
+	}
+}
+
+// The predictor replays pending inputs one per tick after the acked state, like the server applied them
+func TestReplayMatchesServerTicks(t *testing.T) {
+	server := newTestGame(NewTickClock())
+	predictor := newTestGame(NewTickClock())
+	for _, game := range([]*Game { server, predictor }) {
+		game.setSeed(1)
+		game.addPlayer(1)
+		// Settle on the ground
+		stepTicks(game, 60)
+	}
+
+	compare := func() {
+		t.Helper()
+		if predictor.seqNum != server.seqNum || !predictor.grid.Now().Equal(server.grid.Now()) {
+			t.Fatalf("Predictor at tick %d (%v), server at tick %d (%v)", predictor.seqNum, predictor.grid.Now(), server.seqNum, server.grid.Now())
+		}
+
+		serverPlayer := server.grid.Get(Id(playerSpace, 1)).(*Player)
+		predictedPlayer := predictor.grid.Get(Id(playerSpace, 1)).(*Player)
+		if serverPlayer.Pos() != predictedPlayer.Pos() {
+			t.Fatalf("Predicted player at %v, server player at %v on tick %d", predictedPlayer.Pos(), serverPlayer.Pos(), server.seqNum)
+		}
+		for _, timers := range([][2]Timer {
+			{ serverPlayer.jumpTimer, predictedPlayer.jumpTimer },
+			{ serverPlayer.jumpGraceTimer, predictedPlayer.jumpGraceTimer },
+			{ serverPlayer.knockbackTimer, predictedPlayer.knockbackTimer },
+		}) {
+			if !timers[0].started.Equal(timers[1].started) {
+				t.Fatalf("Predicted timer started at %v, server timer at %v on tick %d", timers[1].started, timers[0].started, server.seqNum)
+			}
+		}
+	}
+
+	acked := server.seqNum
+	for i := 1; i <= 10; i++ {
+		keys := []KeyType { rightKey }
+		if i % 4 == 1 {
+			keys = append(keys, jumpKey)
+		}
+		keyMsg := KeyMsg {
+			T: keyType,
+			S: SeqNumType(i),
+			K: keys,
+			M: NewVec2(60, 20),
+		}
+
+		server.processKeyMsg(1, keyMsg)
+		step(server)
+		predictor.processKeyMsg(1, keyMsg)
+		predictor.stepPlayer(1, acked + SeqNumType(i))
+		compare()
+	}
+
+	if server.grid.Get(Id(playerSpace, 1)).(*Player).jumpTimer.started.IsZero() {
+		t.Fatalf("Player never jumped")
+	}
+
+	// Keep stepping past the replay so timers started during it run their course
+	for i := 0; i < ticksFor(jumpDuration); i++ {
+		step(server)
+		step(predictor)
+		compare()
+	}
+}
\ No newline at end of file
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// Target bytes of state per client per tick on the data channel, split into chunks that each fit in a packet.
+	// Websockets have no datagram limit, so clients without a data channel get whatever fits in the largest chunk.
+	defaultStateBudget int = 1200
+	minStateBudget int = 256
+	maxStateBudget int = maxStateChunkSize
+
+	// Objects this far from the player gain priority half as fast
+	priorityFalloff float64 = 8
+
+	// Objects moving this fast gain priority twice as fast
+	prioritySpeed float64 = 10
+
+	// Boost for the player's own projectiles, weapon, etc.
+	ownedPriority float64 = 4
+)
+
+type priorityCandidate struct {
+	sid SpacedId
+	props PropMap
+	priority float64
+}
+
+// Decides which objects fit in each state message for a client. Objects with pending changes build up
+// priority every tick they're left out, so low priority objects are deferred but never starved. Since
+// messages don't contain every object, deltas are against the last acked chunk that included each object.
+// Objects that leave the area of interest are hidden until the client acks it, then sent in full if they return.
+type Priority struct {
+	accumulated map[SpacedId]float64
+	acked map[SpacedId]SeqNumType
+	hiding map[SpacedId]bool
+	// Acks from before an object last left or entered the area don't apply to what the client has now
+	since map[SpacedId]SeqNumType
+	// Objects in each chunk sent for a tick
+	sent map[SeqNumType][][]SpacedId
+	ackSeqNum SeqNumType
+}
+
+// Reads STATE_BUDGET, falling back to the default if unset or invalid
+func StateBudgetFromEnv() int {
+	return sizeFromEnv("STATE_BUDGET", defaultStateBudget, minStateBudget, maxStateBudget)
+}
+
+func NewPriority() *Priority {
+	return &Priority {
+		accumulated: make(map[SpacedId]float64),
+		acked: make(map[SpacedId]SeqNumType),
+		hiding: make(map[SpacedId]bool),
+		since: make(map[SpacedId]SeqNumType),
+		sent: make(map[SeqNumType][][]SpacedId),
+		ackSeqNum: 0,
+	}
+}
+
+// Client has received these chunks of the state for a tick. The same tick is acked again as more
+// of its chunks arrive.
+func (p *Priority) Ack(seqNum SeqNumType, chunks uint32, tick SeqNumType) {
+	if seqNum < p.ackSeqNum || seqNum > tick {
+		return
+	}
+	p.ackSeqNum = seqNum
+
+	for i, sids := range(p.sent[seqNum]) {
+		if chunks & (1 << uint(i)) == 0 {
+			continue
+		}
+		for _, sid := range(sids) {
+			if seqNum < p.since[sid] {
+				continue
+			}
+			if p.hiding[sid] {
+				delete(p.hiding, sid)
+				continue
+			}
+			p.acked[sid] = seqNum
+		}
+	}
+
+	// Anything older was either lost or superseded
+	for t := range(p.sent) {
+		if t < seqNum {
+			delete(p.sent, t)
+		}
+	}
+}
+
+// Fill state messages with the most overdue changes that fit in the budget of bytes, always including the player.
+// size returns how many bytes an object adds to a message.
+func (p *Priority) CreateMsgs(snapshot *Snapshot, interest *Interest, grid *Grid, budget int, chunkSize int, size func(sid SpacedId, props PropMap) int) []GameStateMsg {
+	tick := snapshot.Tick()
+	for t := range(p.sent) {
+		if t + maxBaselineAge < tick {
+			delete(p.sent, t)
+		}
+	}
+	for sid := range(p.acked) {
+		if !snapshot.Has(sid) {
+			delete(p.acked, sid)
+		}
+	}
+	for sid := range(p.accumulated) {
+		if !snapshot.Has(sid) {
+			delete(p.accumulated, sid)
+		}
+	}
+	for sid := range(p.hiding) {
+		if !snapshot.Has(sid) {
+			delete(p.hiding, sid)
+		}
+	}
+	for sid, t := range(p.since) {
+		if !snapshot.Has(sid) || t + maxBaselineAge < tick {
+			delete(p.since, sid)
+		}
+	}
+
+	for _, sid := range(interest.Left()) {
+		p.hiding[sid] = true
+		p.since[sid] = tick
+		delete(p.acked, sid)
+		delete(p.accumulated, sid)
+	}
+	for _, sid := range(interest.Entered()) {
+		delete(p.hiding, sid)
+		p.since[sid] = tick
+		delete(p.acked, sid)
+	}
+
+	var player Object
+	if grid.Has(interest.Player()) {
+		player = grid.Get(interest.Player())
+	}
+
+	candidates := make([]priorityCandidate, 0)
+	for _, sid := range(snapshot.Ids()) {
+		// Objects deleted this tick are removed reliably
+		if !grid.Has(sid) {
+			continue
+		}
+
+		// Hiding is tiny, so always do it first
+		if p.hiding[sid] {
+			candidates = append(candidates, priorityCandidate {
+				sid: sid,
+				props: PropMap { hiddenProp: true },
+				priority: math.Inf(1),
+			})
+			continue
+		}
+		if !interest.Relevant(sid) {
+			continue
+		}
+
+		baseline := p.acked[sid]
+		if !snapshot.ValidBaseline(baseline) {
+			baseline = 0
+		}
+		props := snapshot.ObjectDelta(sid, baseline)
+		if len(props) == 0 {
+			delete(p.accumulated, sid)
+			continue
+		}
+
+		p.accumulated[sid] += p.weight(grid.Get(sid), player)
+		candidates = append(candidates, priorityCandidate {
+			sid: sid,
+			props: props,
+			priority: p.accumulated[sid],
+		})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].sid == interest.Player() || candidates[j].sid == interest.Player() {
+			return candidates[i].sid == interest.Player()
+		}
+		return candidates[i].priority > candidates[j].priority
+	})
+
+	chunker := NewStateChunker(tick, chunkSize)
+	used := 0
+	for _, candidate := range(candidates) {
+		bytes := size(candidate.sid, candidate.props)
+		if used + bytes > budget && used > 0 {
+			continue
+		}
+		if !chunker.Add(candidate.sid, candidate.props, bytes) {
+			break
+		}
+		used += bytes
+		delete(p.accumulated, candidate.sid)
+	}
+	p.sent[tick] = chunker.Ids()
+
+	return chunker.Msgs()
+}
+
+// Nearby and fast moving objects go stale sooner
+func (p *Priority) weight(object Object, player Object) float64 {
+	if player == nil {
+		return 1
+	}
+
+	weight := 1 / (1 + object.Dist(player) / priorityFalloff)
+	weight *= 1 + object.Vel().Len() / prioritySpeed
+	if object.GetOwner() == player.GetSpacedId() {
+		weight *= ownedPriority
+	}
+	return weight
+}
\ No newline at end of file
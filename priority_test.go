@@ -0,0 +1,151 @@
+package main
+
+import (
+	"testing"
+)
+
+func findTestProps(msgs []GameStateMsg, sid SpacedId) (PropMap, bool) {
+	for _, msg := range(msgs) {
+		if props, ok := msg.Os[sid.GetSpace()][sid.GetId()]; ok {
+			return props, true
+		}
+	}
+	return nil, false
+}
+
+func TestPriorityHidesObjectsOutOfInterest(t *testing.T) {
+	game := newTestGame(NewManualClock())
+	player := game.addPlayer(1)
+	// Level pickup, which the client can rebuild from its props
+	pickup := Id(pickupSpace, 0)
+
+	interest := NewInterest(1)
+	priority := NewPriority()
+	var snapshot *Snapshot
+	send := func(pos Vec2) []GameStateMsg {
+		step(game)
+		player.SetPos(pos)
+		game.grid.Upsert(player)
+
+		snapshot = NewSnapshot(game, snapshot)
+		interest.Update(game.grid)
+		return priority.CreateMsgs(snapshot, interest, game.grid, defaultStateBudget, maxStateChunkSize, PackedObjectSize)
+	}
+	ackAll := func() {
+		priority.Ack(snapshot.Tick(), ^uint32(0), snapshot.Tick())
+	}
+	// Everything doesn't fit in the budget at once
+	sendUntilPickup := func(pos Vec2) (PropMap, bool) {
+		for i := 0; i < 20; i++ {
+			if props, ok := findTestProps(send(pos), pickup); ok {
+				return props, true
+			}
+		}
+		return nil, false
+	}
+
+	spawn := player.Pos()
+	far := NewVec2(spawn.X + 10 * interestWidth, spawn.Y)
+
+	if _, ok := sendUntilPickup(spawn); !ok {
+		t.Fatalf("Pickup should be sent while it's nearby")
+	}
+	ackAll()
+
+	// Keep hiding until the client acks it
+	for i := 0; i < 2; i++ {
+		props, ok := findTestProps(send(far), pickup)
+		if !ok || len(props) != 1 || props[hiddenProp] != true {
+			t.Fatalf("Pickup should be hidden after leaving the area, got %v", props)
+		}
+	}
+	ackAll()
+
+	if props, ok := findTestProps(send(far), pickup); ok {
+		t.Errorf("Pickup shouldn't be sent once it's hidden, got %v", props)
+	}
+
+	// The client dropped it, so it needs everything again
+	props, ok := sendUntilPickup(spawn)
+	if !ok {
+		t.Fatalf("Pickup should be sent after coming back")
+	}
+	for _, prop := range([]Prop { posProp, dimProp }) {
+		if _, ok := props[prop]; !ok {
+			t.Errorf("Pickup is missing prop %d after coming back: %v", prop, props)
+		}
+	}
+}
+
+// Walls are only built when the client loads the level, so they have to survive leaving the area
+func TestPriorityKeepsWalls(t *testing.T) {
+	game := newTestGame(NewManualClock())
+	player := game.addPlayer(1)
+
+	// What the client has after loading the level
+	walls := make(map[SpacedId]bool)
+	for id := range(game.createObjectInitMsg().Os[wallSpace]) {
+		walls[Id(wallSpace, id)] = true
+	}
+
+	interest := NewInterest(1)
+	priority := NewPriority()
+	var snapshot *Snapshot
+	send := func(pos Vec2) {
+		step(game)
+		player.SetPos(pos)
+		game.grid.Upsert(player)
+
+		snapshot = NewSnapshot(game, snapshot)
+		interest.Update(game.grid)
+		for _, msg := range(priority.CreateMsgs(snapshot, interest, game.grid, defaultStateBudget, maxStateChunkSize, PackedObjectSize)) {
+			for id, props := range(msg.Os[wallSpace]) {
+				sid := Id(wallSpace, id)
+				if props[hiddenProp] == true {
+					delete(walls, sid)
+				} else if !walls[sid] {
+					t.Errorf("Client can't rebuild wall %v once it's gone", sid)
+				}
+			}
+		}
+		priority.Ack(snapshot.Tick(), ^uint32(0), snapshot.Tick())
+	}
+
+	spawn := player.Pos()
+	far := NewVec2(spawn.X + 10 * interestWidth, spawn.Y)
+	for _, pos := range([]Vec2 { spawn, far, far, spawn }) {
+		for i := 0; i < 20; i++ {
+			send(pos)
+		}
+	}
+
+	if len(walls) != len(game.grid.GetObjects(wallSpace)) {
+		t.Errorf("Client has %d of %d walls after leaving and coming back", len(walls), len(game.grid.GetObjects(wallSpace)))
+	}
+}
+
+func TestPriorityBudget(t *testing.T) {
+	game := newTestGame(NewManualClock())
+	game.addPlayer(1)
+	step(game)
+	snapshot := NewSnapshot(game, nil)
+
+	count := func(budget int) int {
+		interest := NewInterest(1)
+		interest.Update(game.grid)
+		objects := 0
+		for _, msg := range(NewPriority().CreateMsgs(snapshot, interest, game.grid, budget, maxStateChunkSize, PackedObjectSize)) {
+			for _, spaced := range(msg.Os) {
+				objects += len(spaced)
+			}
+		}
+		return objects
+	}
+
+	if objects := count(maxStateBudget); objects != len(snapshot.Ids()) {
+		t.Errorf("Sent %d of %d objects with the websocket budget", objects, len(snapshot.Ids()))
+	}
+	if objects := count(minStateBudget); objects == 0 || objects >= len(snapshot.Ids()) {
+		t.Errorf("Sent %d of %d objects with the smallest budget", objects, len(snapshot.Ids()))
+	}
+}
\ No newline at end of file
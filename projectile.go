@@ -4,6 +4,12 @@ import (
 	"time"
 )
 
+// Projectiles that can be given a collision outside of their own update
+type Collidable interface {
+	Object
+	Collide(collider Object, grid *Grid)
+}
+
 type Projectile struct {
 	BaseObject
 	hits []*Hit
@@ -69,7 +75,7 @@ func (p *Projectile) SetSticky(sticky bool) {
 }
 
 func (p *Projectile) UpdateState(grid *Grid, now time.Time) bool {
-	ts := p.PrepareUpdate(now)
+	ts := grid.Timestep()
 	p.BaseObject.UpdateState(grid, now)
 
 	p.hits = make([]*Hit, 0)
@@ -95,7 +101,7 @@ func (p *Projectile) UpdateState(grid *Grid, now time.Time) bool {
 		return true
 	}
 
-	if p.Expired() {
+	if p.Expired(now) {
 		p.SelfDestruct(grid)
 		return true
 	}
@@ -131,7 +137,7 @@ func (p *Projectile) Collide(collider Object, grid *Grid) {
 
 func (p *Projectile) SelfDestruct(grid *Grid) {
 	if p.collider != nil {
-		p.Hit(p.collider)
+		p.Hit(grid, p.collider)
 	}
 	if p.explode {
 		init := NewObjectInit(grid.NextSpacedId(explosionSpace), p.Pos(), p.explosionSize)	
@@ -140,7 +146,7 @@ func (p *Projectile) SelfDestruct(grid *Grid) {
 	grid.Delete(p.GetSpacedId())	
 }
 
-func (p *Projectile) Hit(collider Object) {
+func (p *Projectile) Hit(grid *Grid, collider Object) {
 	hit := NewHit()
 	hit.SetTarget(collider.GetSpacedId())
 	hit.SetPos(p.Pos())
@@ -148,7 +154,11 @@ func (p *Projectile) Hit(collider Object) {
 
 	switch object := collider.(type) {
 	case *Player:
-		object.TakeDamage(p.GetOwner(), p.GetDamage())
+		damage := int(float64(p.GetDamage()) * grid.GetConfig().DamageMultiplier())
+		if owner, ok := grid.Get(p.GetOwner()).(*Player); ok {
+			damage = int(float64(damage) * owner.stats.DamageMultiplier())
+		}
+		object.TakeDamage(p.GetOwner(), damage, grid.Now())
 	}
 }
 
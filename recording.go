@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+const (
+	recordingVersion uint8 = 1
+	recordingExt string = ".rec"
+)
+
+type RecordEventType uint8
+const (
+	unknownRecordEvent RecordEventType = iota
+	levelRecordEvent
+	joinRecordEvent
+	leftRecordEvent
+	keyRecordEvent
+	endRecordEvent
+	latencyRecordEvent
+)
+
+// Everything needed to recreate the room's Game
+type RecordHeader struct {
+	V uint8
+	Room string
+	Seed int64
+	Regen bool
+	MaxRewind time.Duration
+	Mutators []string
+}
+
+// Tick is the number of simulation steps completed before the event was applied
+type RecordEvent struct {
+	T RecordEventType
+	Tick SeqNumType
+	Id IdType `msgpack:",omitempty"`
+	L LevelIdType `msgpack:",omitempty"`
+	Key *KeyMsg `msgpack:",omitempty"`
+	Latency time.Duration `msgpack:",omitempty"`
+}
+
+func NewRecordHeader(room string, seed int64, config GameConfig) RecordHeader {
+	return RecordHeader {
+		V: recordingVersion,
+		Room: room,
+		Seed: seed,
+		Regen: config.Regen(),
+		MaxRewind: config.MaxRewind(),
+		Mutators: config.Mutators(),
+	}
+}
+
+func (h RecordHeader) GameConfig() (GameConfig, error) {
+	config := NewGameConfig()
+	config.SetRegen(h.Regen)
+	config.SetMaxRewind(h.MaxRewind)
+	for _, mutator := range(h.Mutators) {
+		if err := ApplyMutator(&config, mutator); err != nil {
+			return config, err
+		}
+	}
+	return config, nil
+}
+
+// Records all inputs to a room's Game so the match can be re-simulated
+type Recorder struct {
+	*StreamWriter
+}
+
+func NewRecorder(dir string, header RecordHeader) (*Recorder, error) {
+	name := fmt.Sprintf("%s-%d%s", header.Room, time.Now().Unix(), recordingExt)
+	writer, err := NewStreamWriter(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writer.Encode(&header); err != nil {
+		writer.Close()
+		return nil, err
+	}
+	return &Recorder { writer }, nil
+}
+
+func (r *Recorder) Record(event RecordEvent) error {
+	return r.Encode(&event)
+}
+
+func (r *Recorder) Finish(tick SeqNumType) error {
+	err := r.Record(RecordEvent { T: endRecordEvent, Tick: tick })
+	if closeErr := r.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+type RecordReader struct {
+	*StreamReader
+	header RecordHeader
+}
+
+func NewRecordReader(path string) (*RecordReader, error) {
+	reader, err := NewStreamReader(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &RecordReader {
+		StreamReader: reader,
+	}
+	if err := r.Decode(&r.header); err != nil {
+		r.Close()
+		return nil, err
+	}
+	if r.header.V != recordingVersion {
+		r.Close()
+		return nil, fmt.Errorf("Unsupported recording version %d", r.header.V)
+	}
+	return r, nil
+}
+
+func (r *RecordReader) Header() RecordHeader {
+	return r.header
+}
+
+// Returns io.EOF after the last event
+func (r *RecordReader) Next() (RecordEvent, error) {
+	event := RecordEvent{}
+	err := r.Decode(&event)
+	return event, err
+}
\ No newline at end of file
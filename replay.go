@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// Re-simulates a recorded match
+type Replay struct {
+	reader *RecordReader
+	game *Game
+	snapshot *Snapshot
+	events int
+}
+
+func NewReplay(path string) (*Replay, error) {
+	reader, err := NewRecordReader(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := reader.Header().GameConfig()
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+
+	game := NewGame(config, NewTickClock())
+	game.setSeed(reader.Header().Seed)
+
+	return &Replay {
+		reader: reader,
+		game: game,
+		snapshot: nil,
+		events: 0,
+	}, nil
+}
+
+func (r *Replay) Close() {
+	r.reader.Close()
+}
+
+// Runs the recording to the end, calling onFrame with the messages clients would have received after every tick
+func (r *Replay) Run(keyframe func(tick SeqNumType) bool, onFrame func(frame StateFrame) error) error {
+	for {
+		event, err := r.reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for r.game.seqNum < event.Tick {
+			r.game.updateState(r.game.seqNum + 1, frameTime)
+			frame := NewStateFrame(r.game, r.snapshot, keyframe(r.game.seqNum))
+			if err := onFrame(frame); err != nil {
+				return err
+			}
+			r.snapshot = frame.snapshot
+		}
+
+		if event.T == endRecordEvent {
+			return nil
+		}
+		r.apply(event)
+	}
+}
+
+func (r *Replay) apply(event RecordEvent) {
+	r.events++
+
+	switch event.T {
+	case levelRecordEvent:
+		r.game.loadLevel(event.L)
+	case joinRecordEvent:
+		r.game.addPlayer(event.Id)
+	case leftRecordEvent:
+		r.game.delete(Id(playerSpace, event.Id))
+	case keyRecordEvent:
+		if event.Key != nil {
+			r.game.processKeyMsg(event.Id, *event.Key)
+		}
+	case latencyRecordEvent:
+		r.game.setLatency(event.Id, event.Latency)
+	default:
+		log.Printf("Unknown record event %d at tick %d", event.T, event.Tick)
+	}
+}
+
+// Usage: replay [-out capture] [-json] [-sizes] recording.rec
+func runReplay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	out := flags.String("out", "", "write the state stream to this capture file")
+	printJSON := flags.Bool("json", false, "print every GameStateMsg as JSON")
+	printSizes := flags.Bool("sizes", false, "compare state sizes and encode times for Pack and PackCompact")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("Usage: replay [-out capture%s] [-json] [-sizes] recording%s", captureExt, recordingExt)
+	}
+
+	replay, err := NewReplay(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer replay.Close()
+
+	var writer *CaptureWriter
+	if len(*out) > 0 {
+		writer, err = NewCaptureWriter(*out, replay.reader.Header().Room)
+		if err != nil {
+			return err
+		}
+		defer writer.Close()
+	}
+
+	keyframe := func(tick SeqNumType) bool {
+		return writer != nil && writer.NeedsKeyframe(tick)
+	}
+
+	full := NewCodecBenchmark("full")
+	delta := NewCodecBenchmark("delta")
+	var previous *Snapshot
+
+	encoder := json.NewEncoder(os.Stdout)
+	err = replay.Run(keyframe, func(frame StateFrame) error {
+		if *printSizes {
+			full.Add(frame.snapshot.Msg(), frame.snapshot.Bounds())
+			// Best case for a client that acked the previous tick
+			if previous != nil {
+				delta.Add(frame.snapshot.Delta(previous.Tick()), frame.snapshot.Bounds())
+			}
+			previous = frame.snapshot
+		}
+
+		if writer != nil {
+			if err := writer.Write(frame); err != nil {
+				return err
+			}
+		}
+
+		if *printJSON {
+			for _, b := range([][]byte{frame.PackState(), frame.Update}) {
+				if len(b) == 0 {
+					continue
+				}
+				msg := GameStateMsg{}
+				if err := Unpack(b, &msg); err != nil {
+					return err
+				}
+				if err := encoder.Encode(&msg); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if *printSizes {
+		full.Log()
+		delta.Log()
+	}
+
+	header := replay.reader.Header()
+	log.Printf("Replayed %s: %d ticks, %d events, mutators=%v", header.Room, replay.game.seqNum, replay.events, header.Mutators)
+	for id, object := range(replay.game.grid.GetObjects(playerSpace)) {
+		player := object.(*Player)
+		log.Printf("Player %d: pos=%+v vel=%+v health=%d", id, player.Pos(), player.Vel(), player.GetHealth())
+	}
+	return nil
+}
+
+// Totals for encoding the same messages with Pack and PackCompact
+type CodecBenchmark struct {
+	name string
+	msgs int
+	errors int
+
+	packBytes int
+	compactBytes int
+	packTime time.Duration
+	compactTime time.Duration
+	unpackTime time.Duration
+	unpackCompactTime time.Duration
+}
+
+func NewCodecBenchmark(name string) *CodecBenchmark {
+	return &CodecBenchmark {
+		name: name,
+	}
+}
+
+func (cb *CodecBenchmark) Add(msg GameStateMsg, bounds Bounds) {
+	cb.msgs++
+
+	start := time.Now()
+	packed := Pack(&msg)
+	cb.packTime += time.Since(start)
+	cb.packBytes += len(packed)
+
+	start = time.Now()
+	compact := PackCompact(msg, bounds)
+	cb.compactTime += time.Since(start)
+	cb.compactBytes += len(compact)
+
+	start = time.Now()
+	err := unpackState(packed, &GameStateMsg{})
+	cb.unpackTime += time.Since(start)
+	if err != nil {
+		cb.errors++
+	}
+
+	start = time.Now()
+	decoded, err := UnpackCompact(compact)
+	cb.unpackCompactTime += time.Since(start)
+	if err != nil || decoded.S != msg.S || len(decoded.Os) != len(msg.Os) {
+		cb.errors++
+	}
+}
+
+func (cb CodecBenchmark) Log() {
+	if cb.msgs == 0 {
+		log.Printf("%s: no messages", cb.name)
+		return
+	}
+
+	n := time.Duration(cb.msgs)
+	log.Printf("%s: %d msgs, Pack %dB avg %v/op unpack %v/op, PackCompact %dB avg %v/op unpack %v/op (%.0f%%), %d errors",
+		cb.name, cb.msgs,
+		cb.packBytes / cb.msgs, cb.packTime / n, cb.unpackTime / n,
+		cb.compactBytes / cb.msgs, cb.compactTime / n, cb.unpackCompactTime / n,
+		100 * float64(cb.compactBytes) / Max(1, float64(cb.packBytes)),
+		cb.errors)
+}
\ No newline at end of file
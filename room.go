@@ -3,11 +3,15 @@ package main
 import (
 	"github.com/gorilla/websocket"
 	"log"
+	"strconv"
 	"time"
 )
 
 const (
 	isWasm bool = false
+
+	// Websocket-only clients get state every this many ticks, since a backed up socket delays reliable updates too
+	wsStateInterval SeqNumType = 2
 )
 
 // Incoming client message to parse
@@ -28,8 +32,46 @@ type Msg struct {
 	Left ClientMsg
 }
 
+type RoomConfig struct {
+	game GameConfig
+
+	// Fill room with bots up to this many players
+	bots int
+	botReactionTime time.Duration
+	botAimError float64
+
+	// Record inputs to this directory if set
+	recordDir string
+	// Capture outgoing game state to this directory if set
+	captureDir string
+
+	// Max bytes per state message on the unreliable data channel
+	stateChunkSize int
+	// Target bytes of state per client per tick on the data channel
+	stateBudget int
+	webRTC WebRTCConfig
+}
+
+func NewRoomConfig() RoomConfig {
+	return RoomConfig {
+		game: NewGameConfig(),
+
+		bots: 0,
+		botReactionTime: defaultBotReactionTime,
+		botAimError: defaultBotAimError,
+
+		recordDir: "",
+		captureDir: "",
+
+		stateChunkSize: defaultStateChunkSize,
+		stateBudget: defaultStateBudget,
+		webRTC: WebRTCConfig{},
+	}
+}
+
 type Room struct {
 	id string
+	config RoomConfig
 
 	nextClientId IdType
 	clients map[IdType]*Client
@@ -39,8 +81,14 @@ type Room struct {
 	initQueue []*Client
 	unregister chan *Client
 	unregisterQueue []*Client
+	bots map[IdType]*Bot
+	inputs map[IdType]*InputBuffer
 
 	game *Game
+	recorder *Recorder
+	capture *CaptureWriter
+	snapshot *Snapshot
+	killcam *Killcam
 	ticker *time.Ticker
 	gameTicks int
 	statTicker *time.Ticker
@@ -52,12 +100,14 @@ type Room struct {
 }
 
 var rooms = make(map[string]*Room)
-func NewRoom(roomName string, clientName string, ws *websocket.Conn) {
+// Config is only used if the room doesn't exist yet
+func NewRoom(roomName string, clientName string, compact bool, config RoomConfig, ws *websocket.Conn) {
 	_, roomExists := rooms[roomName]
 
 	if !roomExists {
 		rooms[roomName] = &Room {
 			id: roomName,
+			config: config,
 
 			nextClientId: 0,
 			clients: make(map[IdType]*Client),
@@ -67,8 +117,14 @@ func NewRoom(roomName string, clientName string, ws *websocket.Conn) {
 			initQueue: make([]*Client, 0),
 			unregister: make(chan *Client),
 			unregisterQueue: make([]*Client, 0),
-
-			game: NewGame(),
+			bots: make(map[IdType]*Bot),
+			inputs: make(map[IdType]*InputBuffer),
+
+			game: NewGame(config.game, NewTickClock()),
+			recorder: nil,
+			capture: nil,
+			snapshot: nil,
+			killcam: NewKillcam(),
 			ticker: time.NewTicker(frameTime),
 			gameTicks: 0,
 			statTicker: time.NewTicker(1 * time.Second),
@@ -80,17 +136,26 @@ func NewRoom(roomName string, clientName string, ws *websocket.Conn) {
 		}
 		log.Printf("Created new room %s", roomName)
 
-		rooms[roomName].game.loadLevel(testLevel)
+		rooms[roomName].startRecording()
+		rooms[roomName].startCapture()
+		rooms[roomName].loadLevel(testLevel)
 		go rooms[roomName].run()
 	}
 
-	client := NewClient(rooms[roomName], ws, clientName)
-	rooms[roomName].register <- client
+	room := rooms[roomName]
+	client := NewClient(room, room.nextClientId, ws, clientName, compact)
+	room.nextClientId += 1
+	room.register <- client
 }
 
+func (r *Room) Incoming() chan<- IncomingMsg { return r.incoming }
+func (r *Room) Unregister() chan<- *Client { return r.unregister }
+
 func (r *Room) run() {
 	defer func() {
 		log.Printf("Deleting room %v", r.id)
+		r.stopRecording()
+		r.stopCapture()
 		delete(rooms, r.id)
 	}()
 
@@ -108,8 +173,12 @@ func (r *Room) run() {
 			if len(r.clients) == 0 {
 				continue
 			}
-			r.game.updateState()
+			r.updateBotKeys()
+			r.updateInputs()
+			r.game.updateState(r.game.seqNum + 1, frameTime)
+			r.sendKillcams()
 			r.sendGameState()
+			r.sendPings()
 			r.gameTicks += 1
 		case _ = <-r.statTicker.C:
 			if len(r.clients) == 0 {
@@ -117,6 +186,7 @@ func (r *Room) run() {
 			}
 			log.Printf("FPS: %d", r.gameTicks)
 			r.gameTicks = 0
+			r.logInputStats()
 		default:
 			if len(r.registerQueue) > 0 {
 				for _, client := range(r.registerQueue) {
@@ -165,8 +235,126 @@ func (r *Room) run() {
 	}
 }
 
+func (r *Room) startRecording() {
+	seed := time.Now().UnixNano()
+	r.game.setSeed(seed)
+
+	if len(r.config.recordDir) == 0 {
+		return
+	}
+
+	recorder, err := NewRecorder(r.config.recordDir, NewRecordHeader(r.id, seed, r.config.game))
+	if err != nil {
+		log.Printf("Failed to start recording for %s: %v", r.id, err)
+		return
+	}
+	r.recorder = recorder
+	log.Printf("Recording %s to %s", r.id, recorder.Name())
+}
+
+func (r *Room) startCapture() {
+	if len(r.config.captureDir) == 0 {
+		return
+	}
+
+	capture, err := NewRoomCaptureWriter(r.config.captureDir, r.id)
+	if err != nil {
+		log.Printf("Failed to start capture for %s: %v", r.id, err)
+		return
+	}
+	r.capture = capture
+	log.Printf("Capturing %s to %s", r.id, capture.Name())
+}
+
+func (r *Room) stopCapture() {
+	if r.capture == nil {
+		return
+	}
+
+	if err := r.capture.Close(); err != nil {
+		log.Printf("Failed to finish capture for %s: %v", r.id, err)
+	}
+	r.capture = nil
+}
+
+func (r *Room) stopRecording() {
+	if r.recorder == nil {
+		return
+	}
+
+	if err := r.recorder.Finish(r.game.seqNum); err != nil {
+		log.Printf("Failed to finish recording for %s: %v", r.id, err)
+	}
+	r.recorder = nil
+}
+
+func (r *Room) record(event RecordEvent) {
+	if r.recorder == nil {
+		return
+	}
+
+	event.Tick = r.game.seqNum
+	if err := r.recorder.Record(event); err != nil {
+		log.Printf("Stopped recording %s: %v", r.id, err)
+		r.recorder.Close()
+		r.recorder = nil
+	}
+}
+
+func (r *Room) loadLevel(level LevelIdType) {
+	r.game.loadLevel(level)
+	r.game.buildNavGraph()
+	r.killcam.Reset()
+	r.record(RecordEvent { T: levelRecordEvent, L: level })
+}
+
+func (r *Room) addPlayer(id IdType) {
+	r.game.addPlayer(id)
+	r.record(RecordEvent { T: joinRecordEvent, Id: id })
+}
+
+func (r *Room) deletePlayer(id IdType) {
+	r.game.delete(Id(playerSpace, id))
+	r.record(RecordEvent { T: leftRecordEvent, Id: id })
+}
+
+func (r *Room) processKeyMsg(id IdType, keyMsg KeyMsg) {
+	r.game.processKeyMsg(id, keyMsg)
+	r.record(RecordEvent { T: keyRecordEvent, Id: id, Key: &keyMsg })
+}
+
+// Pings are unreliable like state, so lost ones don't hold up the measurement
+func (r *Room) sendPings() {
+	now := time.Now()
+	for _, c := range(r.clients) {
+		if msg, ok := c.pinger.Ping(now); ok {
+			c.SendUDP(&msg)
+		}
+	}
+}
+
+// Shots are rewound to what the client saw: state that took half a round trip to arrive, after waiting
+// up to one send interval since clients render the latest state they have
+func (r *Room) updateLatency(c *Client) {
+	interpolation := frameTime
+	if c.WebSocketOnly() {
+		interpolation = time.Duration(wsStateInterval) * frameTime
+	}
+
+	latency := (c.pinger.RTT() / 2 + interpolation).Round(time.Millisecond)
+	if latency != c.latency {
+		c.latency = latency
+		r.setLatency(c.id, latency)
+	}
+}
+
+func (r *Room) setLatency(id IdType, latency time.Duration) {
+	r.game.setLatency(id, latency)
+	r.record(RecordEvent { T: latencyRecordEvent, Id: id, Latency: latency })
+}
+
 func (r *Room) registerClient(client *Client) error {
-	err := client.InitWebRTC(func() {
+	err := client.InitWebRTC(r.config.webRTC, func() {
 		r.init <- client
 	})
 	if err != nil {
@@ -191,7 +379,8 @@ func (r *Room) initClient(client *Client) error {
 		return err
 	}
 
-	r.game.add(NewObjectInit(Id(playerSpace, client.id), NewVec2(5, 5), NewVec2(0.8, 1.44)))
+	r.inputs[client.id] = NewInputBuffer()
+	r.addPlayer(client.id)
 	playerInitMsg := r.game.createPlayerInitMsg(client.id)
 	err = client.Send(&playerInitMsg)
 	if err != nil {
@@ -208,23 +397,24 @@ func (r *Room) initClient(client *Client) error {
 		client.Send(&chatMsg)
 	}
 
+	r.fillBots()
+
 	log.Printf("New client %s initialized in %s, total=%d", client.GetDisplayName(), r.id, len(r.clients))
 	return nil
 }
 
 func (r *Room) unregisterClient(client *Client) error {
-	client.ws.Close()
-	if client.dc != nil {
-		client.dc.Close()
-	}
+	client.Close()
 
 	if _, ok := r.clients[client.id]; ok {
 		err := r.updateClients(leftType, client)
 		if err != nil {
 			return err
 		}
-		r.game.delete(Id(playerSpace, client.id))
+		r.deletePlayer(client.id)
 		delete(r.clients, client.id)
+		delete(r.inputs, client.id)
+		r.fillBots()
 	}
 	log.Printf("Unregistering client %s, total=%d", client.GetDisplayName(), len(r.clients))
 
@@ -236,6 +426,13 @@ func (r* Room) processMsg(msg Msg, c* Client) error {
 
 	switch(msg.T) {
 	case pingType:
+		if msg.Ping.E {
+			if c.pinger.Pong(msg.Ping.S, time.Now()) {
+				r.updateLatency(c)
+			}
+			break
+		}
+
 		outMsg := PingMsg {
 			T: pingType,
 			S: msg.Ping.S,
@@ -257,7 +454,14 @@ func (r* Room) processMsg(msg Msg, c* Client) error {
 		outMsg := r.chat.processChatMsg(c, msg.Chat)
 		r.send(&outMsg)
 	case keyType:
-		r.game.processKeyMsg(c.id, msg.Key)
+		c.priority.Ack(msg.Key.A, msg.Key.AC, r.game.seqNum)
+		// Acks aren't input, so keep them out of the buffer and recordings
+		msg.Key.A = 0
+		msg.Key.AC = 0
+
+		if inputs, ok := r.inputs[c.id]; ok {
+			inputs.Push(msg.Key, r.game.seqNum)
+		}
 	default:
 		log.Printf("Unknown message type %d", msg.T)
 	}
@@ -269,9 +473,10 @@ func (r* Room) processMsg(msg Msg, c* Client) error {
 }
 
 func (r *Room) updateClients(msgType MessageType, client *Client) error {
-	msg := r.createClientMsg(msgType, client, false)
+	msg := r.createClientMsg(msgType, client.GetClientData(), false)
 
 	if msgType == initType {
+		msg.WebRTC = r.config.webRTC.Msg(strconv.Itoa(int(client.id)))
 		return client.Send(&msg)
 	} else {
 		r.send(&msg)
@@ -279,30 +484,106 @@ func (r *Room) updateClients(msgType MessageType, client *Client) error {
 	}
 }
 
-func (r *Room) createClientMsg(msgType MessageType, c *Client, voice bool) ClientMsg {
+func (r *Room) createClientMsg(msgType MessageType, data ClientData, voice bool) ClientMsg {
 	msg := ClientMsg {
 		T: msgType,
-		Client: c.GetClientData(),
+		Client: data,
 		Clients: make(map[IdType]ClientData, 0),
 	}
 	for id, client := range r.clients {
-		if (msgType == leftType && id == c.id) || (voice && !client.voice) {
+		if (msgType == leftType && id == data.Id) || (voice && !client.voice) {
 			continue
 		}
 		msg.Clients[id] = client.GetClientData()
 	}
+	if !voice {
+		for id, bot := range r.bots {
+			msg.Clients[id] = bot.GetClientData()
+		}
+	}
 	return msg
 }
 
+// Add or remove bots so the room has the target number of players
+func (r *Room) fillBots() {
+	for len(r.clients) > 0 && len(r.clients) + len(r.bots) < r.config.bots {
+		bot := NewBot(r.nextClientId, r.nextBotNumber(), r.config.botReactionTime, r.config.botAimError)
+		r.nextClientId += 1
+		r.bots[bot.id] = bot
+		r.addPlayer(bot.id)
+		r.updateBots(joinType, bot)
+		log.Printf("Added bot %d to %s", bot.id, r.id)
+	}
+
+	for id := range(r.bots) {
+		if len(r.clients) + len(r.bots) <= r.config.bots {
+			break
+		}
+		bot := r.bots[id]
+		r.deletePlayer(id)
+		delete(r.bots, id)
+		r.updateBots(leftType, bot)
+		log.Printf("Removed bot %d from %s", id, r.id)
+	}
+}
+
+// Bots join and leave like clients, so everyone's client list stays up to date
+func (r *Room) updateBots(msgType MessageType, bot *Bot) {
+	msg := r.createClientMsg(msgType, bot.GetClientData(), false)
+	r.send(&msg)
+}
+
+// Lowest number not taken by another bot, so names are reused as bots come and go
+func (r *Room) nextBotNumber() int {
+	taken := make(map[int]bool, len(r.bots))
+	for _, bot := range(r.bots) {
+		taken[bot.number] = true
+	}
+	number := 1
+	for taken[number] {
+		number++
+	}
+	return number
+}
+
+func (r *Room) updateBotKeys() {
+	for id, bot := range(r.bots) {
+		if keyMsg, ok := bot.CreateKeyMsg(r.game); ok {
+			r.processKeyMsg(id, keyMsg)
+		}
+	}
+}
+
+// Apply exactly one buffered input per player each tick
+func (r *Room) updateInputs() {
+	for id, inputs := range(r.inputs) {
+		if keyMsg, ok := inputs.Pop(); ok {
+			r.processKeyMsg(id, keyMsg)
+		}
+	}
+}
+
+// Only clients whose inputs had problems, since this runs every second
+func (r *Room) logInputStats() {
+	for id, inputs := range(r.inputs) {
+		stats := inputs.FlushStats()
+		if stats.Late == 0 && stats.Duplicate == 0 && stats.Dropped == 0 && stats.Underruns == 0 {
+			continue
+		}
+		log.Printf("Inputs for %s: depth=%d queued=%d late=%d duplicate=%d dropped=%d underruns=%d",
+			r.clients[id].GetDisplayName(), stats.Depth, stats.Queued, stats.Late, stats.Duplicate, stats.Dropped, stats.Underruns)
+	}
+}
+
 func (r *Room) addVoiceClient(c *Client) error {
-	msg := r.createClientMsg(joinVoiceType, c, true)
+	msg := r.createClientMsg(joinVoiceType, c.GetClientData(), true)
 	r.send(&msg)
 	c.voice = true
 	return nil
 }
 
 func (r *Room) removeVoiceClient(c *Client) error {
-	msg := r.createClientMsg(leftVoiceType, c, true)
+	msg := r.createClientMsg(leftVoiceType, c.GetClientData(), true)
 	r.send(&msg)
 	c.voice = false
 	return nil
@@ -327,23 +608,83 @@ func (r *Room) forwardVoiceMessage(msgType MessageType, c *Client, msg JSONPeerM
 }
 
 func (r *Room) send(msg interface{}) {
-	b := Pack(msg)
+	r.sendBytes(Pack(msg))
+}
+
+func (r *Room) sendBytes(b []byte) {
 	for _, c := range(r.clients) {
 		c.SendBytes(b)
 	}
 }
 
 func (r *Room) sendGameState() {
-	state := r.game.createGameStateMsg()
-	r.sendUDP(&state)
+	keyframe := r.capture != nil && r.capture.NeedsKeyframe(r.game.seqNum)
+	frame := NewStateFrame(r.game, r.snapshot, keyframe)
+
+	r.sendSnapshot(frame)
+	if len(frame.Update) > 0 {
+		r.sendBytes(frame.Update)
+	}
 
-	if updates, ok := r.game.createGameUpdateMsg(); ok {
-		r.send(&updates)
+	if r.capture != nil {
+		if err := r.capture.Write(frame); err != nil {
+			log.Printf("Stopped capture for %s: %v", r.id, err)
+			r.stopCapture()
+		}
+	}
+}
+
+func (r *Room) sendKillcams() {
+	for _, kill := range(r.killcam.Record(r.game.grid)) {
+		client, ok := r.clients[kill.victim]
+		if !ok {
+			continue
+		}
+		client.Send(r.killcam.CreateMsg(kill))
+	}
+}
+
+// Send each client the most important changes to relevant objects that fit in its budget
+func (r *Room) sendSnapshot(frame StateFrame) {
+	r.snapshot = frame.snapshot
+	bounds := r.snapshot.Bounds()
+
+	compactSize := func(sid SpacedId, props PropMap) int {
+		return CompactObjectSize(sid.GetId(), props, bounds)
+	}
+
+	for _, c := range(r.clients) {
+		// Nothing is dropped over the websocket, so there's no need to split state or fit it in packets
+		budget := r.config.stateBudget
+		chunkSize := r.config.stateChunkSize
+		if c.WebSocketOnly() {
+			if r.game.seqNum % wsStateInterval != 0 {
+				continue
+			}
+			budget = maxStateBudget
+			chunkSize = maxStateChunkSize
+		}
+
+		// Only once state is sent, so objects that left in between are still hidden
+		c.interest.Update(r.game.grid)
+
+		if c.compact {
+			for _, msg := range(c.priority.CreateMsgs(r.snapshot, c.interest, r.game.grid, budget, chunkSize, compactSize)) {
+				c.SendBytesUDP(PackCompact(msg, bounds))
+			}
+		} else {
+			for _, msg := range(c.priority.CreateMsgs(r.snapshot, c.interest, r.game.grid, budget, chunkSize, PackedObjectSize)) {
+				c.SendUDP(&msg)
+			}
+		}
 	}
 }
 
 func (r *Room) sendUDP(msg interface{}) {
-	b := Pack(msg)
+	r.sendBytesUDP(Pack(msg))
+}
+
+func (r *Room) sendBytesUDP(b []byte) {
 	for _, c := range(r.clients) {
 		c.SendBytesUDP(b)
 	}
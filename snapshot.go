@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"github.com/vmihailenco/msgpack/v5"
+	"log"
+	"reflect"
+	"sort"
+)
+
+const (
+	// Objects the client hasn't acked within this many ticks are sent in full
+	maxBaselineAge SeqNumType = 64
+)
+
+// Events rather than state, so they're over once they're no longer included
+var transientProps = map[Prop]bool {
+	hitsProp: true,
+}
+
+type snapshotProp struct {
+	data interface{}
+	packed []byte
+
+	// Last tick the value was different
+	changed SeqNumType
+}
+
+// Full state of every object after a tick, plus when each prop last changed. A client that has
+// applied tick B only needs the props that changed after B, even if they later changed back.
+type Snapshot struct {
+	tick SeqNumType
+	bounds Bounds
+	objects map[SpacedId]map[Prop]snapshotProp
+}
+
+func NewSnapshot(game *Game, previous *Snapshot) *Snapshot {
+	s := &Snapshot {
+		tick: game.seqNum,
+		bounds: NewBounds(game.grid),
+		objects: make(map[SpacedId]map[Prop]snapshotProp),
+	}
+
+	var buf bytes.Buffer
+	encoder := msgpack.NewEncoder(&buf)
+
+	for space, objects := range(game.grid.GetObjectSnapshot()) {
+		for id, props := range(objects) {
+			sid := Id(space, id)
+			var last map[Prop]snapshotProp
+			if previous != nil {
+				last = previous.objects[sid]
+			}
+
+			entries, err := newSnapshotProps(encoder, &buf, s.tick, props, last)
+			if err != nil {
+				log.Printf("Skipping %v in snapshot %d: %v", sid, s.tick, err)
+				continue
+			}
+			s.objects[sid] = entries
+		}
+	}
+	return s
+}
+
+func newSnapshotProps(encoder *msgpack.Encoder, buf *bytes.Buffer, tick SeqNumType, props PropMap, last map[Prop]snapshotProp) (map[Prop]snapshotProp, error) {
+	entries := make(map[Prop]snapshotProp, len(props))
+	for prop, data := range(props) {
+		buf.Reset()
+		if err := encodeSorted(encoder, data); err != nil {
+			return nil, err
+		}
+
+		entry := snapshotProp {
+			data: data,
+			packed: append([]byte(nil), buf.Bytes()...),
+			changed: tick,
+		}
+		if lastEntry, ok := last[prop]; ok && bytes.Equal(lastEntry.packed, entry.packed) {
+			entry.changed = lastEntry.changed
+		}
+		entries[prop] = entry
+	}
+
+	// Some props are only included for a few ticks after they change, but their last value still holds
+	for prop, lastEntry := range(last) {
+		if _, ok := entries[prop]; !ok && !transientProps[prop] {
+			entries[prop] = lastEntry
+		}
+	}
+	return entries, nil
+}
+
+func (s Snapshot) Tick() SeqNumType {
+	return s.tick
+}
+
+func (s Snapshot) Bounds() Bounds {
+	return s.bounds
+}
+
+func (s Snapshot) Msg() GameStateMsg {
+	return s.Delta(0)
+}
+
+func (s Snapshot) Ids() []SpacedId {
+	ids := make([]SpacedId, 0, len(s.objects))
+	for sid := range(s.objects) {
+		ids = append(ids, sid)
+	}
+	return ids
+}
+
+func (s Snapshot) Has(sid SpacedId) bool {
+	_, ok := s.objects[sid]
+	return ok
+}
+
+// Props that changed after the baseline tick, or everything if the baseline is 0. Deletions are
+// sent reliably, so objects that no longer exist are skipped.
+func (s Snapshot) Delta(baseline SeqNumType) GameStateMsg {
+	objects := make(ObjectPropMap)
+	for sid := range(s.objects) {
+		props := s.ObjectDelta(sid, baseline)
+		if len(props) == 0 {
+			continue
+		}
+
+		if _, ok := objects[sid.GetSpace()]; !ok {
+			objects[sid.GetSpace()] = make(SpacedPropMap)
+		}
+		objects[sid.GetSpace()][sid.GetId()] = props
+	}
+
+	return GameStateMsg {
+		T: objectDataType,
+		S: s.tick,
+		Os: objects,
+	}
+}
+
+// Same as Delta for a single object
+func (s Snapshot) ObjectDelta(sid SpacedId, baseline SeqNumType) PropMap {
+	props := make(PropMap)
+	for prop, entry := range(s.objects[sid]) {
+		if baseline > 0 && entry.changed <= baseline {
+			continue
+		}
+		props[prop] = entry.data
+	}
+	return props
+}
+
+// Whether a tick the client acked can be used to send a delta
+func (s Snapshot) ValidBaseline(baseline SeqNumType) bool {
+	return baseline > 0 && baseline < s.tick && s.tick - baseline <= maxBaselineAge
+}
+
+// Maps are otherwise packed in random order, so equal values wouldn't compare equal. msgpack's
+// SetSortMapKeys only handles string keys, whereas ours are mostly small integer enums.
+func encodeSorted(encoder *msgpack.Encoder, data interface{}) error {
+	v := reflect.ValueOf(data)
+
+	// Slices can hold maps, e.g. hits. Bytes are packed as binary instead of an array.
+	if v.Kind() == reflect.Slice && !v.IsNil() && v.Type().Elem().Kind() != reflect.Uint8 {
+		if err := encoder.EncodeArrayLen(v.Len()); err != nil {
+			return err
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeSorted(encoder, v.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if v.Kind() != reflect.Map || v.IsNil() {
+		return encoder.Encode(data)
+	}
+
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		switch keys[i].Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return keys[i].Int() < keys[j].Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return keys[i].Uint() < keys[j].Uint()
+		case reflect.String:
+			return keys[i].String() < keys[j].String()
+		}
+		return false
+	})
+
+	if err := encoder.EncodeMapLen(len(keys)); err != nil {
+		return err
+	}
+	for _, key := range(keys) {
+		if err := encoder.Encode(key.Interface()); err != nil {
+			return err
+		}
+		if err := encodeSorted(encoder, v.MapIndex(key).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
\ No newline at end of file
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"github.com/vmihailenco/msgpack/v5"
+	"testing"
+)
+
+func packSorted(t *testing.T, data interface{}) []byte {
+	var buf bytes.Buffer
+	if err := encodeSorted(msgpack.NewEncoder(&buf), data); err != nil {
+		t.Fatalf("Failed to encode %v: %v", data, err)
+	}
+	return buf.Bytes()
+}
+
+func TestEncodeSortedSlices(t *testing.T) {
+	hits := func() []PropMap {
+		hits := make([]PropMap, 0)
+		for i := 0; i < 3; i++ {
+			hits = append(hits, PropMap {
+				targetProp: Id(playerSpace, IdType(i)),
+				posProp: NewVec2(float64(i), 1),
+				dimProp: NewVec2(1, 1),
+				velProp: NewVec2(0, 0),
+			})
+		}
+		return hits
+	}
+
+	// Map iteration order is random, so pack enough times to catch an unsorted map
+	expected := packSorted(t, hits())
+	for i := 0; i < 20; i++ {
+		if packed := packSorted(t, hits()); !bytes.Equal(packed, expected) {
+			t.Fatalf("Equal slices of maps packed differently")
+		}
+	}
+
+	decoded := make([]PropMap, 0)
+	if err := msgpack.Unmarshal(expected, &decoded); err != nil || len(decoded) != 3 {
+		t.Errorf("Sorted slice didn't decode as a slice: %v", err)
+	}
+
+	if packed, err := msgpack.Marshal([]byte { 1, 2, 3 }); err != nil || !bytes.Equal(packSorted(t, []byte { 1, 2, 3 }), packed) {
+		t.Errorf("Bytes should be packed as binary")
+	}
+}
+
+func TestSnapshotTransientProps(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := msgpack.NewEncoder(&buf)
+
+	first, err := newSnapshotProps(encoder, &buf, 1, PropMap {
+		posProp: NewVec2(1, 1),
+		hitsProp: []PropMap { PropMap { posProp: NewVec2(1, 1) } },
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create snapshot props: %v", err)
+	}
+
+	second, err := newSnapshotProps(encoder, &buf, 2, PropMap {
+		velProp: NewVec2(1, 0),
+	}, first)
+	if err != nil {
+		t.Fatalf("Failed to create snapshot props: %v", err)
+	}
+
+	if _, ok := second[posProp]; !ok {
+		t.Errorf("Position wasn't carried forward")
+	} else if second[posProp].changed != 1 {
+		t.Errorf("Carried position changed at %d, want 1", second[posProp].changed)
+	}
+	if _, ok := second[hitsProp]; ok {
+		t.Errorf("Hits were carried forward")
+	}
+}
\ No newline at end of file
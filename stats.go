@@ -0,0 +1,193 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+type PowerUpType uint8
+const (
+	unknownPowerUp PowerUpType = iota
+	speedPowerUp
+	damagePowerUp
+	jumpPowerUp
+	gravityPowerUp
+)
+
+const (
+	speedPowerUpMultiplier float64 = 1.4
+	damagePowerUpMultiplier float64 = 2.0
+	jumpPowerUpAirJumps int = 2
+	gravityPowerUpMultiplier float64 = 0.5
+)
+
+// Player movement and combat values with power-up modifiers applied
+type Stats struct {
+	physics PhysicsConfig
+	// Remaining simulation time for each active power-up
+	powerUps map[PowerUpType]time.Duration
+	powerUpsFlag *Flag
+	lastRemaining map[PowerUpType]uint8
+}
+
+func NewStats() Stats {
+	return Stats {
+		physics: NewPhysicsConfig(),
+		powerUps: make(map[PowerUpType]time.Duration),
+		powerUpsFlag: NewFlag(),
+		lastRemaining: make(map[PowerUpType]uint8),
+	}
+}
+
+func (s *Stats) SetPhysics(physics PhysicsConfig) {
+	s.physics = physics
+}
+
+func (s Stats) Physics() PhysicsConfig {
+	return s.physics
+}
+
+func (s *Stats) AddPowerUp(powerUp PowerUpType, duration time.Duration) {
+	s.powerUps[powerUp] = duration
+	s.powerUpsFlag.Reset(true)
+}
+
+func (s *Stats) ClearPowerUps() {
+	if len(s.powerUps) == 0 {
+		return
+	}
+
+	s.powerUps = make(map[PowerUpType]time.Duration)
+	s.powerUpsFlag.Reset(true)
+}
+
+func (s Stats) HasPowerUp(powerUp PowerUpType) bool {
+	remaining, ok := s.powerUps[powerUp]
+	return ok && remaining > 0
+}
+
+// Count down power-ups by ts seconds and flag changes in remaining seconds for replication.
+func (s *Stats) UpdatePowerUps(ts float64) {
+	changed := false
+	elapsed := time.Duration(ts * float64(time.Second))
+	for powerUp, remaining := range(s.powerUps) {
+		remaining -= elapsed
+		if remaining <= 0 {
+			delete(s.powerUps, powerUp)
+			changed = true
+			continue
+		}
+		s.powerUps[powerUp] = remaining
+	}
+
+	remaining := s.getRemaining()
+	if len(remaining) != len(s.lastRemaining) {
+		changed = true
+	} else {
+		for powerUp, seconds := range(remaining) {
+			if last, ok := s.lastRemaining[powerUp]; !ok || last != seconds {
+				changed = true
+				break
+			}
+		}
+	}
+	s.lastRemaining = remaining
+
+	if changed {
+		s.powerUpsFlag.Reset(true)
+	}
+}
+
+func (s Stats) Gravity() float64 {
+	if s.HasPowerUp(gravityPowerUp) {
+		return s.physics.Gravity * gravityPowerUpMultiplier
+	}
+	return s.physics.Gravity
+}
+
+func (s Stats) DownAcc() float64 {
+	if s.HasPowerUp(gravityPowerUp) {
+		return s.physics.DownAcc * gravityPowerUpMultiplier
+	}
+	return s.physics.DownAcc
+}
+
+func (s Stats) SideAcc() float64 {
+	return s.physics.SideAcc * s.SpeedMultiplier()
+}
+
+func (s Stats) MaxHorizontalVel() float64 {
+	return s.physics.MaxHorizontalVel * s.SpeedMultiplier()
+}
+
+func (s Stats) SpeedMultiplier() float64 {
+	if s.HasPowerUp(speedPowerUp) {
+		return speedPowerUpMultiplier
+	}
+	return 1
+}
+
+func (s Stats) DamageMultiplier() float64 {
+	if s.HasPowerUp(damagePowerUp) {
+		return damagePowerUpMultiplier
+	}
+	return 1
+}
+
+func (s Stats) JumpVel() float64 {
+	return s.physics.JumpVel
+}
+
+func (s Stats) AirJumps() int {
+	if s.HasPowerUp(jumpPowerUp) {
+		return 1 + jumpPowerUpAirJumps
+	}
+	return 1
+}
+
+func (s Stats) getRemaining() map[PowerUpType]uint8 {
+	remaining := make(map[PowerUpType]uint8)
+	for powerUp, duration := range(s.powerUps) {
+		if duration <= 0 {
+			continue
+		}
+		seconds := math.Ceil(duration.Seconds())
+		remaining[powerUp] = uint8(Min(seconds, math.MaxUint8))
+	}
+	return remaining
+}
+
+func (s Stats) GetInitData() Data {
+	data := NewData()
+	if len(s.powerUps) > 0 {
+		data.Set(powerUpsProp, s.getRemaining())
+	}
+	return data
+}
+
+func (s Stats) GetData() Data {
+	data := NewData()
+	if _, ok := s.powerUpsFlag.Pop(); ok {
+		data.Set(powerUpsProp, s.getRemaining())
+	}
+	return data
+}
+
+func (s Stats) GetUpdates() Data {
+	updates := NewData()
+	if _, ok := s.powerUpsFlag.GetOnce(); ok {
+		updates.Set(powerUpsProp, s.getRemaining())
+	}
+	return updates
+}
+
+func (s *Stats) SetData(data Data) {
+	if !data.Has(powerUpsProp) {
+		return
+	}
+
+	s.powerUps = make(map[PowerUpType]time.Duration)
+	for powerUp, seconds := range(data.Get(powerUpsProp).(map[PowerUpType]uint8)) {
+		s.powerUps[powerUp] = time.Duration(seconds) * time.Second
+	}
+}
\ No newline at end of file
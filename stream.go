@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"github.com/vmihailenco/msgpack/v5"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Gzipped stream of msgpack values, used for recordings and captured game state
+type StreamWriter struct {
+	file *os.File
+	gzip *gzip.Writer
+	buffer *bufio.Writer
+	encoder *msgpack.Encoder
+}
+
+func NewStreamWriter(path string) (*StreamWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &StreamWriter {
+		file: file,
+		gzip: gzip.NewWriter(file),
+	}
+	w.buffer = bufio.NewWriter(w.gzip)
+	w.encoder = msgpack.NewEncoder(w.buffer)
+	return w, nil
+}
+
+func (w *StreamWriter) Name() string {
+	return w.file.Name()
+}
+
+func (w *StreamWriter) Encode(v interface{}) error {
+	return w.encoder.Encode(v)
+}
+
+// Ends the current gzip member and starts another, so readers can start decompressing at the boundary
+func (w *StreamWriter) Segment() error {
+	if err := w.buffer.Flush(); err != nil {
+		return err
+	}
+	if err := w.gzip.Close(); err != nil {
+		return err
+	}
+	w.gzip.Reset(w.file)
+	return nil
+}
+
+func (w *StreamWriter) Close() error {
+	defer w.file.Close()
+
+	if err := w.buffer.Flush(); err != nil {
+		return err
+	}
+	return w.gzip.Close()
+}
+
+type StreamReader struct {
+	file *os.File
+	gzip *gzip.Reader
+	decoder *msgpack.Decoder
+}
+
+func NewStreamReader(path string) (*StreamReader, error) {
+	return NewStreamReaderAt(path, 0)
+}
+
+// Offset must be the start of a gzip member
+func NewStreamReaderAt(path string, offset int64) (*StreamReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &StreamReader {
+		file: file,
+		gzip: gz,
+		decoder: msgpack.NewDecoder(bufio.NewReader(gz)),
+	}, nil
+}
+
+// Returns io.EOF at the end of the stream, including for truncated files
+func (r *StreamReader) Decode(v interface{}) error {
+	err := r.decoder.Decode(v)
+	if err == io.ErrUnexpectedEOF {
+		return io.EOF
+	}
+	return err
+}
+
+func (r *StreamReader) Close() {
+	r.gzip.Close()
+	r.file.Close()
+}
\ No newline at end of file
@@ -27,17 +27,17 @@ func (t *Timer) SetDuration(duration time.Duration) {
 	t.duration = duration
 }
 
-func (t *Timer) Start() {
-	t.started = time.Now()
+func (t *Timer) Start(now time.Time) {
+	t.started = now
 }
 
-func (t Timer) On() bool {
-	elapsed := t.Elapsed()
+func (t Timer) On(now time.Time) bool {
+	elapsed := t.Elapsed(now)
 	return 0 <= elapsed && elapsed < t.duration
 }
 
-func (t Timer) Elapsed() time.Duration {
-	elapsed := time.Now().Sub(t.started.Add(t.delay))
+func (t Timer) Elapsed(now time.Time) time.Duration {
+	elapsed := now.Sub(t.started.Add(t.delay))
 
 	if elapsed < 0 {
 		return 0
@@ -46,8 +46,17 @@ func (t Timer) Elapsed() time.Duration {
 }
 
 
-func (t Timer) Lerp(min float64, max float64) float64 {
-	ts := float64(t.Elapsed() / t.duration)
+func (t Timer) Remaining(now time.Time) time.Duration {
+	remaining := t.duration - t.Elapsed(now)
+
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+func (t Timer) Lerp(now time.Time, min float64, max float64) float64 {
+	ts := float64(t.Elapsed(now) / t.duration)
 
 	return min + ts * (max - min)
 }
\ No newline at end of file
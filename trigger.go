@@ -139,7 +139,7 @@ func (t *Trigger) Reload() { t.ammo = t.maxAmmo }
 
 func (t *Trigger) UpdateState(grid *Grid, now time.Time) {
 	if t.Ammo() > 0 && (t.Pressed() || t.Ammo() < t.MaxAmmo()) {
-		if t.ammoTimer.On() {
+		if t.ammoTimer.On(now) {
 			t.state = rotatingAmmoTriggerState
 			return
 		}
@@ -160,7 +160,7 @@ func (t *Trigger) UpdateState(grid *Grid, now time.Time) {
 			return
 		}
 	} else if t.Ammo() == 0 {
-		if t.reloadTimer.On() {
+		if t.reloadTimer.On(now) {
 			t.state = reloadingTriggerState
 			return
 		} else {
@@ -187,8 +187,8 @@ func (t *Trigger) Shoot(grid *Grid, now time.Time) {
 	}
 
 	t.ammo -= 1
-	t.ammoTimer.Start()
-	t.reloadTimer.Start()
+	t.ammoTimer.Start(now)
+	t.reloadTimer.Start(now)
 
 	if isWasm {
 		return
@@ -220,6 +220,7 @@ func (t *Trigger) Shoot(grid *Grid, now time.Time) {
 	jerk.Scale(t.ProjectileJerk())
 	projectile.SetJerk(jerk)
 
+	t.compensateLag(grid, projectile)
 	grid.Upsert(projectile)
 
 	if t.ProjectileLimit() > 0 {
@@ -227,6 +228,71 @@ func (t *Trigger) Shoot(grid *Grid, now time.Time) {
 	}
 }
 
+// Favor the shooter by checking the start of the shot against where targets were on their screen
+func (t *Trigger) compensateLag(grid *Grid, object Object) {
+	shooter, ok := grid.Get(t.weapon.GetOwner()).(*Player)
+	if !ok {
+		return
+	}
+	projectile, ok := object.(Collidable)
+	if !ok {
+		return
+	}
+
+	ticks := grid.RewindTicks(shooter.Latency())
+	if ticks == 0 {
+		return
+	}
+
+	origin := projectile.Pos()
+	vel := projectile.Vel()
+	acc := projectile.Acc()
+	ts := grid.Timestep()
+
+	// Step i of the shot is checked against targets as they were ticks - i ago. Walls don't move so they're checked as is.
+	pos := origin
+	for i := 0; i < ticks; i++ {
+		projectile.SetPos(pos)
+		for _, collider := range(grid.GetColliders(projectile)) {
+			if collider.object.GetSpace() != playerSpace {
+				projectile.SetPos(origin)
+				return
+			}
+		}
+
+		tick := grid.Tick() - SeqNumType(ticks - i)
+		for id, object := range(grid.GetObjects(playerSpace)) {
+			player := object.(*Player)
+			if player.GetSpacedId() == shooter.GetSpacedId() || player.Dead() {
+				continue
+			}
+			past, ok := grid.HistoricalPos(id, tick)
+			if !ok {
+				continue
+			}
+
+			// Move the shot by the target's displacement instead of moving the target back. A hit leaves it on the target.
+			offset := player.Pos()
+			offset.Sub(past, 1.0)
+			shifted := pos
+			shifted.Add(offset, 1.0)
+			projectile.SetPos(shifted)
+
+			result := OverlapHit(projectile, player)
+			if result.hit {
+				projectile.Stick(result)
+				projectile.Collide(player, grid)
+				return
+			}
+		}
+
+		acc.Add(projectile.Jerk(), ts)
+		vel.Add(acc, ts)
+		pos.Add(vel, ts)
+	}
+	projectile.SetPos(origin)
+}
+
 func (t *Trigger) OnDelete(grid *Grid) {
 	t.deleteTrackedProjectiles(grid)
 }
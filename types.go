@@ -36,6 +36,7 @@ const (
 	objectUpdateType
 	playerInitType
 	levelInitType
+	killcamType
 )
 
 type IdType uint16
@@ -62,6 +63,8 @@ const (
 	objectStatesProp
 	initializedProp
 	deletedProp
+	// Left the client's area of interest, so it should stop drawing the object until it's sent again
+	hiddenProp
 
 	attributesProp
 	byteAttributesProp
@@ -81,6 +84,8 @@ const (
 
 	killProp
 	deathProp
+	powerUpsProp
+	keySeqNumProp
 )
 
 type AttributeType uint8
@@ -94,6 +99,7 @@ const (
 	groundedAttribute
 	attachedAttribute
 	deadAttribute
+	hiddenAttribute
 )
 
 type ByteAttributeType uint8
@@ -103,6 +109,11 @@ const (
 	typeByteAttribute
 	healthByteAttribute
 	juiceByteAttribute
+	armorByteAttribute
+	shieldByteAttribute
+	pickupByteAttribute
+	amountByteAttribute
+	timerByteAttribute
 )
 
 type LevelIdType uint8
@@ -136,6 +147,9 @@ type SpacedPropMap map[IdType]PropMap
 type PingMsg struct {
 	T MessageType
 	S SeqNumType
+
+	// Set on pings from the server, which the client echoes back so the server can measure round trip time
+	E bool `msgpack:",omitempty"`
 }
 
 type JSONMsg struct {
@@ -159,6 +173,21 @@ type ClientMsg struct {
 	T MessageType
 	Client ClientData
 	Clients map[IdType]ClientData
+
+	// Only set in the init message
+	WebRTC *WebRTCMsg `msgpack:",omitempty"`
+}
+
+type WebRTCMsg struct {
+	Servers []ICEServerMsg
+	// Only connect through TURN servers
+	Relay bool `msgpack:",omitempty"`
+}
+
+type ICEServerMsg struct {
+	URLs []string
+	Username string `msgpack:",omitempty"`
+	Credential string `msgpack:",omitempty"`
 }
 
 type ChatMsg struct {
@@ -171,6 +200,12 @@ type GameStateMsg struct {
 	T MessageType
 	S SeqNumType
 	Os ObjectPropMap
+
+	// Index among the state messages for tick S, which each hold different objects
+	C uint8 `msgpack:",omitempty" json:",omitempty"`
+
+	// Only set in the game init message
+	Ms []string `msgpack:",omitempty" json:",omitempty"`
 }
 
 type PlayerInitMsg struct {
@@ -182,6 +217,10 @@ type PlayerInitMsg struct {
 type LevelInitMsg struct {
 	T MessageType
 	L LevelIdType
+	P PhysicsConfig
+
+	// Head hitbox scale, which the predictor needs since heads collide with walls too
+	H float64 `msgpack:",omitempty"`
 }
 
 type KeyMsg struct {
@@ -190,4 +229,9 @@ type KeyMsg struct {
 	K []KeyType // keys
 	M Vec2 // mouse
 	D Vec2 // direction
+
+	// Latest GameStateMsg.S received by the client, used as its delta baseline
+	A SeqNumType `msgpack:",omitempty"`
+	// Bitmask of the chunks of A received, since only those objects can be used as baselines
+	AC uint32 `msgpack:",omitempty"`
 }
\ No newline at end of file
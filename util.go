@@ -22,14 +22,12 @@ func Unpack(b []byte, msg interface{}) error {
 	return nil
 }
 
-func GetTimestep(now time.Time, lastTime time.Time) float64 {
-	var timeStep time.Duration
-	if lastTime.IsZero() {
-		timeStep = 0
-	} else {
-		timeStep = now.Sub(lastTime)
-	}
-	return float64(timeStep) / float64(time.Second)
+// Nonzero so timers that were never started are never on
+var simEpoch = time.Unix(0, 0)
+
+// Simulation time after tick fixed steps of length timestep
+func SimTime(tick SeqNumType, timestep time.Duration) time.Time {
+	return simEpoch.Add(time.Duration(tick) * timestep)
 }
 
 func NormalizeAngle(rad float64) float64 {
@@ -132,7 +130,12 @@ func FSignPos(n float64) float64 {
 }
 
 func Clamp(min, n, max float64) float64 {
-	return Max(Min(min, n), max)
+	return Max(min, Min(n, max))
+}
+
+// For byte attributes, which would wrap around instead of saturating
+func ClampByte(n int) uint8 {
+	return uint8(Clamp(0, float64(n), math.MaxUint8))
 }
 
 func And(bools ...bool) bool {
@@ -44,7 +44,7 @@ func (w *Wall) UpdateState(grid *Grid, now time.Time) bool {
 		return false
 	}
 
-	ts := w.PrepareUpdate(now)
+	ts := grid.Timestep()
 	pos := w.Pos()
 	vel := w.Vel()
 	if w.xBounded {
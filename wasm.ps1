@@ -1,4 +1,4 @@
-[string[]]$src_files = @("game.go", "association.go", "attachment.go", "attribute.go", "charger.go", "collideroptions.go", "circle.go", "data.go", "expiration.go", "explosion.go", "flag.go", "gamestate.go", "grid.go", "health.go", "hit.go", "init.go", "keys.go", "level.go", "log.go", "object.go", "objectheap.go", "objects.go", "optional.go", "player.go", "profile.go", "profilemath.go", "projectile.go", "projectiles.go", "rec2.go", "rotpoly.go", "state.go", "structs.go", "subprofile.go", "timer.go", "trigger.go", "types.go", "util.go", "wall.go", "weapon.go")
+[string[]]$src_files = @("game.go", "association.go", "attachment.go", "attribute.go", "charger.go", "clock.go", "collideroptions.go", "config.go", "circle.go", "codec.go", "data.go", "expiration.go", "explosion.go", "flag.go", "gamestate.go", "grid.go", "health.go", "hit.go", "init.go", "keys.go", "level.go", "navgraph.go", "log.go", "object.go", "objectheap.go", "objects.go", "optional.go", "pickup.go", "player.go", "profile.go", "profilemath.go", "projectile.go", "projectiles.go", "rec2.go", "rotpoly.go", "state.go", "stats.go", "structs.go", "subprofile.go", "timer.go", "trigger.go", "types.go", "util.go", "wall.go", "weapon.go")
 
 foreach ($file in $src_files) {
 	cp "$($file)" "wasm/tmp_$($file)"
@@ -26,6 +26,7 @@ type Weapon struct {
 
 type WeaponPart interface {
 	SetPressed(pressed bool)
+	Reload()
 	UpdateState(grid *Grid, now time.Time)
 	OnDelete(grid *Grid)
 }
@@ -104,12 +105,17 @@ func (w *Weapon) SetWeaponType(weaponType WeaponType) {
 	w.SetByteAttribute(typeByteAttribute, uint8(weaponType))
 }
 
+func (w *Weapon) Reload() {
+	for _, part := range(w.parts) {
+		part.Reload()
+	}
+}
+
 func (w *Weapon) OnGrounded() {
 	w.jetpack = 80
 }
 
 func (w *Weapon) UpdateState(grid *Grid, now time.Time) bool {
-	w.PrepareUpdate(now)
 	w.BaseObject.UpdateState(grid, now)
 
 	player := grid.Get(w.GetOwner())
@@ -122,11 +128,11 @@ func (w *Weapon) UpdateState(grid *Grid, now time.Time) bool {
 				jet.Scale(scale)
 				player.AddForce(jet)
 				w.jetpack -= 1
-			} else if weaponType == starWeapon && !w.dashTimer.On() {
+			} else if weaponType == starWeapon && !w.dashTimer.On(now) {
 				dash := w.Dir()
-				dash.Scale(4 * jumpVel)
+				dash.Scale(4 * grid.GetConfig().Physics().JumpVel)
 				player.SetVel(dash)
-				w.dashTimer.Start()
+				w.dashTimer.Start(now)
 			}
 		}
 	}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"github.com/pion/webrtc/v3"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Long enough to outlast a session, since the TURN server checks it again when allocations are refreshed
+	defaultTURNTTL time.Duration = 6 * time.Hour
+)
+
+var defaultSTUNURLs = []string {
+	"stun:stun.l.google.com:19302",
+	"stun:stun2.l.google.com:19302",
+	"stun:openrelay.metered.ca:80",
+}
+
+// ICE servers and network restrictions shared by the server and its clients. Read from the environment:
+//   STUN_URLS: comma separated, replaces the public defaults
+//   TURN_URLS, TURN_SECRET: relay server and the secret it shares with us (coturn's static-auth-secret), used to
+//     sign time limited credentials for each peer connection so the secret itself is never sent to clients
+//   TURN_TTL: how long those credentials last, e.g. 2h
+//   TURN_USERNAME, TURN_CREDENTIAL: static credentials for TURN servers that only issue those instead of TURN_SECRET.
+//     Every client gets them as is, so they're only used with TURN_STATIC_CREDENTIALS=1
+//   ICE_RELAY_ONLY: only connect through TURN, hiding both peers' addresses
+//   ICE_NETWORK_TYPES: comma separated pion network types, e.g. udp4
+//   ICE_PORT_RANGE: min-max UDP ports for the server's candidates, to match firewall rules
+type WebRTCConfig struct {
+	servers []webrtc.ICEServer
+	turnURLs []string
+	turnSecret string
+	turnTTL time.Duration
+	turnUsername string
+	turnCredential string
+	relayOnly bool
+
+	networkTypes []webrtc.NetworkType
+	portMin uint16
+	portMax uint16
+}
+
+func NewWebRTCConfigFromEnv() WebRTCConfig {
+	wc := WebRTCConfig {
+		servers: make([]webrtc.ICEServer, 0),
+		turnURLs: nil,
+		turnSecret: "",
+		turnTTL: defaultTURNTTL,
+		turnUsername: "",
+		turnCredential: "",
+		relayOnly: false,
+
+		networkTypes: nil,
+		portMin: 0,
+		portMax: 0,
+	}
+
+	stunURLs := defaultSTUNURLs
+	if value := os.Getenv("STUN_URLS"); len(value) > 0 {
+		stunURLs = splitList(value)
+	}
+	if len(stunURLs) > 0 {
+		wc.servers = append(wc.servers, webrtc.ICEServer {
+			URLs: stunURLs,
+		})
+	}
+
+	if turnURLs := splitList(os.Getenv("TURN_URLS")); len(turnURLs) > 0 {
+		username := os.Getenv("TURN_USERNAME")
+		credential := os.Getenv("TURN_CREDENTIAL")
+		static := len(username) > 0 || len(credential) > 0
+		if secret := os.Getenv("TURN_SECRET"); len(secret) > 0 {
+			if static {
+				log.Printf("TURN_USERNAME and TURN_CREDENTIAL are ignored since TURN_SECRET is set")
+			}
+			wc.turnURLs = turnURLs
+			wc.turnSecret = secret
+		} else if !static {
+			log.Printf("TURN_URLS is set without TURN_SECRET or TURN_USERNAME and TURN_CREDENTIAL, ignoring it")
+		} else if value := os.Getenv("TURN_STATIC_CREDENTIALS"); len(value) == 0 || value == "0" {
+			log.Printf("TURN_USERNAME and TURN_CREDENTIAL are sent to every client, set TURN_STATIC_CREDENTIALS=1 to use them anyway or TURN_SECRET instead")
+		} else if len(username) == 0 || len(credential) == 0 {
+			log.Printf("TURN_USERNAME and TURN_CREDENTIAL should both be set, ignoring TURN_URLS")
+		} else {
+			wc.turnURLs = turnURLs
+			wc.turnUsername = username
+			wc.turnCredential = credential
+		}
+	}
+	if value := os.Getenv("TURN_TTL"); len(value) > 0 {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl <= 0 {
+			log.Printf("Invalid TURN_TTL %s, should be a duration like 2h", value)
+		} else {
+			wc.turnTTL = ttl
+		}
+	}
+
+	if value := os.Getenv("ICE_RELAY_ONLY"); len(value) > 0 && value != "0" {
+		if len(wc.turnURLs) == 0 {
+			log.Printf("ICE_RELAY_ONLY is set without a TURN server, ignoring it")
+		} else {
+			wc.relayOnly = true
+		}
+	}
+
+	for _, name := range(splitList(os.Getenv("ICE_NETWORK_TYPES"))) {
+		networkType, err := webrtc.NewNetworkType(name)
+		if err != nil {
+			log.Printf("Invalid ICE_NETWORK_TYPES: %v", err)
+			continue
+		}
+		wc.networkTypes = append(wc.networkTypes, networkType)
+	}
+
+	if value := os.Getenv("ICE_PORT_RANGE"); len(value) > 0 {
+		ports := strings.Split(value, "-")
+		if len(ports) != 2 {
+			log.Printf("Invalid ICE_PORT_RANGE %s, should be min-max", value)
+		} else {
+			portMin, errMin := strconv.ParseUint(strings.TrimSpace(ports[0]), 10, 16)
+			portMax, errMax := strconv.ParseUint(strings.TrimSpace(ports[1]), 10, 16)
+			if errMin != nil || errMax != nil || portMin == 0 || portMax < portMin {
+				log.Printf("Invalid ICE_PORT_RANGE %s, should be min-max", value)
+			} else {
+				wc.portMin = uint16(portMin)
+				wc.portMax = uint16(portMax)
+			}
+		}
+	}
+	return wc
+}
+
+func (wc WebRTCConfig) NewPeerConnection() (*webrtc.PeerConnection, error) {
+	settings := webrtc.SettingEngine{}
+	if len(wc.networkTypes) > 0 {
+		settings.SetNetworkTypes(wc.networkTypes)
+	}
+	if wc.portMin > 0 {
+		if err := settings.SetEphemeralUDPPortRange(wc.portMin, wc.portMax); err != nil {
+			return nil, err
+		}
+	}
+
+	config := webrtc.Configuration {
+		ICEServers: wc.iceServers("server", time.Now()),
+	}
+	if wc.relayOnly {
+		config.ICETransportPolicy = webrtc.ICETransportPolicyRelay
+	}
+
+	api := webrtc.NewAPI(webrtc.WithSettingEngine(settings))
+	return api.NewPeerConnection(config)
+}
+
+// Sent in the init message so the client uses the same servers, with its own TURN credentials
+func (wc WebRTCConfig) Msg(user string) *WebRTCMsg {
+	servers := wc.iceServers(user, time.Now())
+	msg := &WebRTCMsg {
+		Servers: make([]ICEServerMsg, 0, len(servers)),
+		Relay: wc.relayOnly,
+	}
+	for _, server := range(servers) {
+		credential, _ := server.Credential.(string)
+		msg.Servers = append(msg.Servers, ICEServerMsg {
+			URLs: server.URLs,
+			Username: server.Username,
+			Credential: credential,
+		})
+	}
+	return msg
+}
+
+func (wc WebRTCConfig) iceServers(user string, now time.Time) []webrtc.ICEServer {
+	servers := append(make([]webrtc.ICEServer, 0, len(wc.servers) + 1), wc.servers...)
+	if len(wc.turnURLs) > 0 {
+		username, credential := wc.turnCredentials(user, now)
+		servers = append(servers, webrtc.ICEServer {
+			URLs: wc.turnURLs,
+			Username: username,
+			Credential: credential,
+			CredentialType: webrtc.ICECredentialTypePassword,
+		})
+	}
+	return servers
+}
+
+// TURN REST API credentials: the username is when they expire plus who they're for, and the credential
+// is its HMAC with the shared secret, which the TURN server checks without needing to know about users.
+// Without a secret everyone shares the static credentials.
+func (wc WebRTCConfig) turnCredentials(user string, now time.Time) (string, string) {
+	if len(wc.turnSecret) == 0 {
+		return wc.turnUsername, wc.turnCredential
+	}
+
+	username := fmt.Sprintf("%d:%s", now.Add(wc.turnTTL).Unix(), user)
+	mac := hmac.New(sha1.New, []byte(wc.turnSecret))
+	mac.Write([]byte(username))
+	return username, base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func splitList(value string) []string {
+	list := make([]string, 0)
+	for _, item := range(strings.Split(value, ",")) {
+		item = strings.TrimSpace(item)
+		if len(item) > 0 {
+			list = append(list, item)
+		}
+	}
+	return list
+}
\ No newline at end of file
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTURNCredentials(t *testing.T) {
+	t.Setenv("TURN_URLS", "turn:turn.example.com:3478")
+	t.Setenv("TURN_SECRET", "secret")
+	t.Setenv("TURN_TTL", "1h")
+	wc := NewWebRTCConfigFromEnv()
+
+	username, credential := wc.turnCredentials("7", time.Unix(1700000000, 0))
+	if username != "1700003600:7" || credential != "0eDwZf8jAPpI3e06Kag2mHkrySk=" {
+		t.Errorf("Got credentials %s %s", username, credential)
+	}
+
+	msg := wc.Msg("7")
+	turn := msg.Servers[len(msg.Servers) - 1]
+	if !strings.HasSuffix(turn.Username, ":7") || len(turn.Credential) == 0 {
+		t.Errorf("TURN server is missing credentials: %+v", turn)
+	}
+	for _, server := range(msg.Servers) {
+		if server.Username == "secret" || server.Credential == "secret" {
+			t.Errorf("Sent the shared secret: %+v", server)
+		}
+	}
+}
+
+func TestTURNStaticCredentials(t *testing.T) {
+	t.Setenv("TURN_URLS", "turn:turn.example.com:3478")
+	t.Setenv("TURN_USERNAME", "user")
+	t.Setenv("TURN_CREDENTIAL", "pass")
+
+	turnServer := func(wc WebRTCConfig) (ICEServerMsg, bool) {
+		for _, server := range(wc.Msg("7").Servers) {
+			if len(server.URLs) > 0 && strings.HasPrefix(server.URLs[0], "turn:") {
+				return server, true
+			}
+		}
+		return ICEServerMsg{}, false
+	}
+
+	// Clients would see them, so they need an explicit opt-in
+	if server, ok := turnServer(NewWebRTCConfigFromEnv()); ok {
+		t.Errorf("Used static credentials without opting in: %+v", server)
+	}
+
+	t.Setenv("TURN_STATIC_CREDENTIALS", "1")
+	server, ok := turnServer(NewWebRTCConfigFromEnv())
+	if !ok || server.Username != "user" || server.Credential != "pass" {
+		t.Errorf("Expected static credentials, got %+v", server)
+	}
+
+	// The shared secret takes precedence
+	t.Setenv("TURN_SECRET", "secret")
+	server, ok = turnServer(NewWebRTCConfigFromEnv())
+	if !ok || !strings.HasSuffix(server.Username, ":7") || server.Credential == "pass" {
+		t.Errorf("Expected credentials signed with the secret, got %+v", server)
+	}
+}
\ No newline at end of file